@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/go-redis/redis/v8"
 	"math/rand"
 	"reflect"
@@ -30,6 +31,16 @@ type Options struct {
 	Rt           reflect.Type
 	Expires      time.Duration
 	EmptyExpires time.Duration
+
+	// 为空时使用InitRedisClient设置的默认client
+	Client redis.UniversalClient
+	// 不为空时先用布隆过滤器检查key，一定不存在的key直接返回空，不查cache也不回源
+	Bloom *BloomFilter
+	// 不为空时统计key的访问次数，发现热key
+	HotKey *HotKeyDetector
+	// 为空时使用SetNamespace设置的全局namespace和version
+	Namespace string
+	Version   int
 }
 
 func (o *Options) validate() error {
@@ -39,9 +50,19 @@ func (o *Options) validate() error {
 	if o.Rt == nil {
 		return errors.New("Rt must not be empty!")
 	}
+	if o.client() == nil {
+		return ErrClientNotInitialized
+	}
 	return nil
 }
 
+func (o *Options) client() redis.UniversalClient {
+	if o.Client != nil {
+		return o.Client
+	}
+	return GetRedisClient()
+}
+
 // 返回的三个参数，依次是: cache值，是否空，错误信息
 func GetCacheValueItem(v interface{}) (string, bool, error) {
 	cacheV := ""
@@ -62,6 +83,34 @@ func GetCacheValueItem(v interface{}) (string, bool, error) {
 	return cacheV, cLength == 0, nil
 }
 
+// 集合类型的回填：在MULTI里先删除再整体写入并设置过期时间，
+// 并发回填时后提交的整体覆盖先提交的，读者不会看到写了一半或者重复写入的集合
+func rewriteCollection(ctx context.Context, cli redis.UniversalClient, key string, expires time.Duration, fill func(pipe redis.Pipeliner)) error {
+	_, err := cli.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		fill(pipe)
+		pipe.Expire(ctx, key, expires)
+		return nil
+	})
+	return err
+}
+
+// cache里的值反序列化失败，AOP把它当作miss重新加载并覆盖
+var errCacheDecode = errors.New("cache value decode error")
+
+// 把cache里取出的字符串按Rt反序列化，string类型直接返回
+func decodeCacheValue(cacheV string, rt reflect.Type) (interface{}, error) {
+	if rt.Kind() == reflect.String {
+		return cacheV, nil
+	}
+	rv := reflect.New(rt).Interface()
+	err := json.Unmarshal([]byte(cacheV), rv)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCacheDecode, err)
+	}
+	return reflect.ValueOf(rv).Elem().Interface(), nil
+}
+
 func getRand() int {
 	rand.Seed(time.Now().UnixNano())
 	return rand.Intn(10000000)
@@ -0,0 +1,222 @@
+package g_rediscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"reflect"
+	"time"
+)
+
+type BatchOptions struct {
+	Options
+
+	// Key 在这里是模板，每个id的缓存key为 fmt.Sprintf(Key, id)
+	Ids []interface{}
+}
+
+func (o *BatchOptions) validate() error {
+	err := o.Options.validate()
+	if err != nil {
+		return err
+	}
+	if len(o.Ids) == 0 {
+		return errors.New("Ids must not be empty!")
+	}
+	return nil
+}
+
+// BatchAop 一次MGET取回所有id，只把缺失的id交给fallback，回填时每个id单独设置过期时间
+// fallback返回的map必须以传入的id作为key，没有出现在map里的id视为不存在，
+// 如果设置了EmptyExpires会给这些id缓存空值
+// 返回的map只包含有值的id，bool表示是否全部来自cache
+func BatchAop(options *BatchOptions, fallback func(missIds []interface{}) (map[interface{}]interface{}, error)) (map[interface{}]interface{}, bool, error) {
+	err := options.validate()
+	if err != nil {
+		return nil, false, err
+	}
+	metric := startMetric(aopBatch, options.Key)
+	defer metric.done()
+	result := make(map[interface{}]interface{}, len(options.Ids))
+	allKeys := make([]string, len(options.Ids))
+	redisKeys := make([]string, len(options.Ids))
+	keyTpl := options.cacheKey()
+	for i, id := range options.Ids {
+		allKeys[i] = fmt.Sprintf(options.Key, id)
+		redisKeys[i] = fmt.Sprintf(keyTpl, id)
+	}
+	// 布隆过滤器判断一定不存在的id不查cache也不回源
+	// 热key直接从本地缓存取
+	var ids []interface{}
+	var keys []string
+	promotes := make(map[string]bool)
+	for i, allow := range options.bloomAllowMulti(allKeys) {
+		if !allow {
+			metric.event(EventBloomReject)
+			continue
+		}
+		local, localHit, promote := options.hotKeyGet(redisKeys[i])
+		if localHit {
+			metric.event(EventLocalHit)
+			result[options.Ids[i]] = local
+			continue
+		}
+		if promote {
+			promotes[redisKeys[i]] = true
+		}
+		ids = append(ids, options.Ids[i])
+		keys = append(keys, redisKeys[i])
+	}
+	if len(keys) == 0 {
+		return result, len(result) > 0, nil
+	}
+
+	var missIds []interface{}
+	var missKeys []string
+	cacheVs, err := mget(options.Ctx, options.client(), keys)
+	if err != nil {
+		logrus.Warn("[REDIS][BATCH] mget error, maybe load all from db! ", err)
+		cacheVs = make([]interface{}, len(keys))
+	}
+	for i, cacheV := range cacheVs {
+		str, ok := cacheV.(string)
+		if !ok || str == "" {
+			metric.event(EventMiss)
+			missIds = append(missIds, ids[i])
+			missKeys = append(missKeys, keys[i])
+			continue
+		}
+		if str == EmptyFlag {
+			metric.event(EventEmptyHit)
+			continue
+		}
+		v, err := decodeCacheValue(str, options.Rt)
+		if err != nil {
+			// 反序列化失败的id按miss处理，回填时覆盖
+			metric.event(EventDecodeError)
+			logrus.Warn("[REDIS][BATCH] key ", keys[i], " decode error! ", err)
+			missIds = append(missIds, ids[i])
+			missKeys = append(missKeys, keys[i])
+			continue
+		}
+		metric.event(EventHit)
+		result[ids[i]] = v
+		if promotes[keys[i]] {
+			options.hotKeyPut(keys[i], v)
+		}
+	}
+	if len(missIds) == 0 {
+		return result, true, nil
+	}
+
+	logrus.Warn("[REDIS][BATCH] ", len(missIds), " ids missed in redis cache, maybe load from db!")
+	loaded, err := fallback(missIds)
+	if err != nil {
+		metric.event(EventFallbackError)
+		return nil, false, err
+	}
+
+	if options.Expires == 0 {
+		options.Expires = defaultExpire
+	}
+	pipe := options.client().Pipeline()
+	for i, id := range missIds {
+		item, ok := loaded[id]
+		if ok && item != nil {
+			cacheV, isEmpty, err := GetCacheValueItem(item)
+			if err != nil {
+				logrus.Warn("[REDIS][BATCH] GetCacheValueItem error!", err)
+				continue
+			}
+			if !isEmpty {
+				result[id] = item
+				pipe.Set(options.Ctx, missKeys[i], cacheV, options.Expires)
+				if promotes[missKeys[i]] {
+					options.hotKeyPut(missKeys[i], item)
+				}
+				continue
+			}
+		}
+		// 空值回填
+		if options.EmptyExpires > 0 {
+			pipe.Set(options.Ctx, missKeys[i], EmptyFlag, options.EmptyExpires)
+		}
+	}
+	if pipe.Len() > 0 {
+		if _, err := pipe.Exec(options.Ctx); err != nil {
+			metric.event(EventFillError)
+			logrus.Warn("[REDIS][BATCH] rewrite cache error! ", err)
+		}
+	}
+	return result, false, nil
+}
+
+// 集群模式下MGET不能跨slot，改成pipeline逐个GET，由client按节点拆分
+func mget(ctx context.Context, cli redis.UniversalClient, keys []string) ([]interface{}, error) {
+	if _, ok := cli.(*redis.ClusterClient); !ok {
+		return cli.MGet(ctx, keys...).Result()
+	}
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := cli.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, key)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	cacheVs := make([]interface{}, len(keys))
+	for i, cmd := range cmds {
+		if v, err := cmd.Result(); err == nil {
+			cacheVs[i] = v
+		}
+	}
+	return cacheVs, nil
+}
+
+type BatchAopProxy struct {
+	options BatchOptions
+}
+
+func (p *BatchAopProxy) WithExpires(expires time.Duration) *BatchAopProxy {
+	p.options.Expires = expires
+	return p
+}
+
+func (p *BatchAopProxy) WithEmptyExpires(emptyExpires time.Duration) *BatchAopProxy {
+	p.options.EmptyExpires = emptyExpires
+	return p
+}
+
+func (p *BatchAopProxy) WithClient(cli redis.UniversalClient) *BatchAopProxy {
+	p.options.Client = cli
+	return p
+}
+
+func (p *BatchAopProxy) WithBloom(bloom *BloomFilter) *BatchAopProxy {
+	p.options.Bloom = bloom
+	return p
+}
+
+func (p *BatchAopProxy) WithHotKey(detector *HotKeyDetector) *BatchAopProxy {
+	p.options.HotKey = detector
+	return p
+}
+
+func (p *BatchAopProxy) WithNamespace(namespace string, version int) *BatchAopProxy {
+	p.options.Namespace = namespace
+	p.options.Version = version
+	return p
+}
+
+func (p *BatchAopProxy) Then(f func(missIds []interface{}) (map[interface{}]interface{}, error)) (map[interface{}]interface{}, bool, error) {
+	return BatchAop(&p.options, f)
+}
+
+// keyTpl 例如 "user:%d"
+func UseBatchAop(ctx context.Context, keyTpl string, rt reflect.Type, ids []interface{}) *BatchAopProxy {
+	return &BatchAopProxy{BatchOptions{Options{Ctx: ctx, Key: keyTpl, Rt: rt}, ids}}
+}
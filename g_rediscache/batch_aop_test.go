@@ -0,0 +1,83 @@
+package g_rediscache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func ExampleUseBatchAop() {
+	RedisTestSetup()
+	keyTpl := "testtest_batch_" + strconv.Itoa(getRand()) + ":%v"
+	bizFunc := func(missIds []interface{}) (map[interface{}]interface{}, error) {
+		r := make(map[interface{}]interface{})
+		for _, id := range missIds {
+			r[id] = User{Id: id.(int64), Name: "name" + strconv.FormatInt(id.(int64), 10)}
+		}
+		return r, nil
+	}
+	ids := []interface{}{int64(1), int64(2)}
+	_, fromCache, _ := UseBatchAop(context.Background(), keyTpl, reflect.TypeOf(User{}), ids).WithExpires(5 * time.Second).Then(bizFunc)
+	fmt.Println(fromCache)
+	_, fromCache, _ = UseBatchAop(context.Background(), keyTpl, reflect.TypeOf(User{}), ids).WithExpires(5 * time.Second).Then(bizFunc)
+	fmt.Println(fromCache)
+	// output:
+	// false
+	// true
+}
+
+func TestBatchAop(t *testing.T) {
+	RedisTestSetup()
+	keyTpl := "testtest_batch_" + strconv.Itoa(getRand()) + ":%v"
+
+	options := &BatchOptions{}
+	options.Ctx = context.Background()
+	options.Key = keyTpl
+	options.Rt = reflect.TypeOf(User{})
+	options.Expires = 30 * time.Second
+	options.EmptyExpires = 10 * time.Second
+
+	// 第一次只缓存1和2
+	options.Ids = []interface{}{int64(1), int64(2)}
+	val, fromCache, err := BatchAop(options, func(missIds []interface{}) (map[interface{}]interface{}, error) {
+		if len(missIds) != 2 {
+			t.Fatal("1. missIds must be all ids", missIds)
+		}
+		return map[interface{}]interface{}{int64(1): User{Id: 1}, int64(2): User{Id: 2}}, nil
+	})
+	if err != nil || fromCache || len(val) != 2 {
+		t.Fatal("1. must not be from cache FAIL", err)
+	}
+
+	// 第二次只有3, 4需要回源，4不存在
+	options.Ids = []interface{}{int64(1), int64(2), int64(3), int64(4)}
+	val, fromCache, err = BatchAop(options, func(missIds []interface{}) (map[interface{}]interface{}, error) {
+		if len(missIds) != 2 || missIds[0] != int64(3) || missIds[1] != int64(4) {
+			t.Fatal("2. missIds must be 3 and 4", missIds)
+		}
+		return map[interface{}]interface{}{int64(3): User{Id: 3}}, nil
+	})
+	if err != nil || fromCache || len(val) != 3 || val[int64(1)].(User).Id != 1 || val[int64(3)].(User).Id != 3 {
+		t.Fatal("2. partial miss FAIL", err, val)
+	}
+	cacheV, _ := GetRedisClient().Get(context.Background(), fmt.Sprintf(keyTpl, 4)).Result()
+	if cacheV != EmptyFlag {
+		t.Fatal("2.1 empty cache FAIL")
+	}
+	ttl, _ := GetRedisClient().TTL(context.Background(), fmt.Sprintf(keyTpl, 4)).Result()
+	if ttl <= 0 || ttl > options.EmptyExpires {
+		t.Fatal("2.2 empty expires FAIL", ttl)
+	}
+
+	// 第三次全部命中，包括空值
+	val, fromCache, err = BatchAop(options, func(missIds []interface{}) (map[interface{}]interface{}, error) {
+		t.Fatal("3. fallback must not be called", missIds)
+		return nil, nil
+	})
+	if err != nil || !fromCache || len(val) != 3 {
+		t.Fatal("3. must be from cache FAIL", err)
+	}
+}
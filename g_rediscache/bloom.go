@@ -0,0 +1,188 @@
+package g_rediscache
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/zsyu9779/myUtil/hash"
+	"math"
+)
+
+const (
+	// redis的bitmap最大512MB
+	maxBloomBits      = uint64(1) << 32
+	defaultWarmupSize = 1000
+)
+
+// BloomFilter 存在redis bitmap里的布隆过滤器，用来拦截一定不存在的key，防止缓存穿透
+// EmptyFlag只能挡住重复的key，随机构造的key每次都是新的，仍然会打到db
+//
+// AOP里检查的是缓存key（BatchAop是每个id对应的key），
+// 所以Add/Warmup的也必须是缓存key，新数据写入db后要记得Add，否则会被误拦截
+type BloomFilter struct {
+	key string
+	// bit数
+	m uint64
+	// hash函数个数，第i个hash函数使用seed i
+	k uint32
+
+	// 为空时使用InitRedisClient设置的默认client
+	client redis.UniversalClient
+}
+
+// NewBloomFilter key是存储bitmap的redis key，n是预计的元素个数，fp是期望的误判率
+func NewBloomFilter(key string, n uint64, fp float64) *BloomFilter {
+	if n == 0 {
+		n = 1
+	}
+	if fp <= 0 || fp >= 1 {
+		fp = 0.01
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	if m > maxBloomBits {
+		m = maxBloomBits
+	}
+	k := uint32(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k == 0 {
+		k = 1
+	}
+	return &BloomFilter{key: key, m: m, k: k}
+}
+
+func (b *BloomFilter) WithClient(cli redis.UniversalClient) *BloomFilter {
+	b.client = cli
+	return b
+}
+
+// Size 返回bit数
+func (b *BloomFilter) Size() uint64 {
+	return b.m
+}
+
+// HashCount 返回hash函数个数
+func (b *BloomFilter) HashCount() uint32 {
+	return b.k
+}
+
+// Add 把item加入过滤器
+func (b *BloomFilter) Add(ctx context.Context, items ...string) error {
+	cli, err := b.getClient()
+	if err != nil {
+		return err
+	}
+	_, err = cli.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, item := range items {
+			for _, offset := range b.offsets(item) {
+				pipe.SetBit(ctx, b.key, offset, 1)
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// Exists 返回false表示item一定不存在，返回true表示可能存在
+func (b *BloomFilter) Exists(ctx context.Context, item string) (bool, error) {
+	exists, err := b.ExistsMulti(ctx, []string{item})
+	if err != nil {
+		return false, err
+	}
+	return exists[0], nil
+}
+
+// ExistsMulti 一次pipeline检查多个item，结果和items一一对应
+func (b *BloomFilter) ExistsMulti(ctx context.Context, items []string) ([]bool, error) {
+	cli, err := b.getClient()
+	if err != nil {
+		return nil, err
+	}
+	cmds := make([][]*redis.IntCmd, len(items))
+	_, err = cli.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, item := range items {
+			for _, offset := range b.offsets(item) {
+				cmds[i] = append(cmds[i], pipe.GetBit(ctx, b.key, offset))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	exists := make([]bool, len(items))
+	for i := range items {
+		exists[i] = true
+		for _, cmd := range cmds[i] {
+			if cmd.Val() == 0 {
+				exists[i] = false
+				break
+			}
+		}
+	}
+	return exists, nil
+}
+
+// Warmup 分批把已有的数据加入过滤器，batchSize<=0时默认每批1000个
+func (b *BloomFilter) Warmup(ctx context.Context, items []string, batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = defaultWarmupSize
+	}
+	for start := 0; start < len(items); start += batchSize {
+		end := start + batchSize
+		if end > len(items) {
+			end = len(items)
+		}
+		if err := b.Add(ctx, items[start:end]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WarmupIds 按key模板把已有的id转成缓存key再加入过滤器，keyTpl和UseBatchAop的一致，例如 "user:%d"
+func (b *BloomFilter) WarmupIds(ctx context.Context, keyTpl string, ids []interface{}, batchSize int) error {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = fmt.Sprintf(keyTpl, id)
+	}
+	return b.Warmup(ctx, keys, batchSize)
+}
+
+func (b *BloomFilter) offsets(item string) []int64 {
+	offsets := make([]int64, b.k)
+	for i := uint32(0); i < b.k; i++ {
+		offsets[i] = int64(uint64(hash.Murmurhash3([]byte(item), i)) % b.m)
+	}
+	return offsets
+}
+
+func (b *BloomFilter) getClient() (redis.UniversalClient, error) {
+	if b.client != nil {
+		return b.client, nil
+	}
+	if cli := GetRedisClient(); cli != nil {
+		return cli, nil
+	}
+	return nil, ErrClientNotInitialized
+}
+
+// 布隆过滤器判断key一定不存在时返回false，没有设置过滤器或者查询出错时放行
+func (o *Options) bloomAllow(key string) bool {
+	return o.bloomAllowMulti([]string{key})[0]
+}
+
+func (o *Options) bloomAllowMulti(keys []string) []bool {
+	allow := make([]bool, len(keys))
+	for i := range allow {
+		allow[i] = true
+	}
+	if o.Bloom == nil {
+		return allow
+	}
+	exists, err := o.Bloom.ExistsMulti(o.Ctx, keys)
+	if err != nil {
+		logrus.Warn("[REDIS][BLOOM] check keys error, skip bloom filter! ", err)
+		return allow
+	}
+	return exists
+}
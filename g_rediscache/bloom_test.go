@@ -0,0 +1,82 @@
+package g_rediscache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewBloomFilter(t *testing.T) {
+	b := NewBloomFilter("testtest_bloom", 1000000, 0.01)
+	// m = -n*ln(p)/(ln2)^2 ≈ 9585059, k = m/n*ln2 ≈ 7
+	if b.Size() != 9585059 || b.HashCount() != 7 {
+		t.Fatal("size or hash count not expected", b.Size(), b.HashCount())
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	RedisTestSetup()
+	bloomKey := "testtest_bloom_" + strconv.Itoa(getRand())
+	defer GetRedisClient().Del(context.Background(), bloomKey)
+
+	b := NewBloomFilter(bloomKey, 10000, 0.001)
+	var items []string
+	for i := 0; i < 1000; i++ {
+		items = append(items, "item:"+strconv.Itoa(i))
+	}
+	if err := b.Warmup(context.Background(), items, 100); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		exists, err := b.Exists(context.Background(), item)
+		if err != nil || !exists {
+			t.Fatal("added item must exist", item, err)
+		}
+	}
+	falsePositive := 0
+	for i := 1000; i < 2000; i++ {
+		exists, _ := b.Exists(context.Background(), "item:"+strconv.Itoa(i))
+		if exists {
+			falsePositive++
+		}
+	}
+	if falsePositive > 10 {
+		t.Fatal("too many false positives", falsePositive)
+	}
+}
+
+func TestBloomFilter_Aop(t *testing.T) {
+	RedisTestSetup()
+	bloomKey := "testtest_bloom_" + strconv.Itoa(getRand())
+	keyTpl := "testtest_bloom_user_" + strconv.Itoa(getRand()) + ":%v"
+	defer GetRedisClient().Del(context.Background(), bloomKey)
+
+	b := NewBloomFilter(bloomKey, 1000, 0.001)
+	if err := b.WarmupIds(context.Background(), keyTpl, []interface{}{1, 2}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// 不存在的key不会回源
+	v, fromCache, err := UseSimpleAop(context.Background(), fmt.Sprintf(keyTpl, 3), reflect.TypeOf(User{})).
+		WithBloom(b).Then(func() (interface{}, error) {
+		t.Fatal("fallback must not be called")
+		return nil, nil
+	})
+	if err != nil || fromCache || v != nil {
+		t.Fatal("bloom reject FAIL", err)
+	}
+
+	val, _, err := UseBatchAop(context.Background(), keyTpl, reflect.TypeOf(User{}), []interface{}{1, 2, 3}).
+		WithBloom(b).WithExpires(10 * time.Second).Then(func(missIds []interface{}) (map[interface{}]interface{}, error) {
+		if len(missIds) != 2 {
+			t.Fatal("only 1 and 2 can be loaded", missIds)
+		}
+		return map[interface{}]interface{}{1: User{Id: 1}, 2: User{Id: 2}}, nil
+	})
+	if err != nil || len(val) != 2 {
+		t.Fatal("batch bloom FAIL", err, val)
+	}
+}
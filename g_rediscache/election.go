@@ -0,0 +1,202 @@
+package g_rediscache
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultLeaseTTL      = 10 * time.Second
+	defaultCampaignRetry = time.Second
+)
+
+// 和releaseLockScript一样先比较token，只有自己还持有锁时才续期
+var renewLockScript = redis.NewScript(`
+if redis.call('get', KEYS[1]) == ARGV[1] then
+	return redis.call('pexpire', KEYS[1], ARGV[2])
+else
+	return 0
+end
+`)
+
+var ErrCampaignRunning = errors.New("election is already campaigning")
+
+type ElectionOptions struct {
+	// 非空，参与同一个选举的实例使用相同的Key
+	Key string
+	// leader的租期，默认10s，leader宕机后最多TTL之后会选出新的leader
+	TTL time.Duration
+	// 续期的间隔，默认TTL/3
+	RenewInterval time.Duration
+	// 没有选上时重新竞选的间隔，默认1s
+	RetryInterval time.Duration
+
+	// 为空时使用InitRedisClient设置的默认client
+	Client redis.UniversalClient
+
+	// 当选时回调，ctx在失去leader身份时被取消，需要长期运行的任务在回调里另起goroutine并监听ctx
+	OnElected func(ctx context.Context)
+	// 失去leader身份时回调，包括主动退出、续期失败和租期被别人抢走
+	OnRevoked func()
+}
+
+// Election 基于redis的leader选举，同一时刻最多只有一个实例的Leader()为true
+// leader通过 SET NX 抢到Key，之后定时续期，续期和释放都会比较token，不会影响别人的租期
+type Election struct {
+	options ElectionOptions
+	id      string
+
+	leader      int32
+	campaigning int32
+}
+
+func NewElection(options ElectionOptions) (*Election, error) {
+	if options.Key == "" {
+		return nil, errors.New("Key must not be empty!")
+	}
+	if options.TTL <= 0 {
+		options.TTL = defaultLeaseTTL
+	}
+	if options.RenewInterval <= 0 {
+		options.RenewInterval = options.TTL / 3
+	}
+	if options.RetryInterval <= 0 {
+		options.RetryInterval = defaultCampaignRetry
+	}
+	return &Election{options: options, id: NewObjectID().Hex()}, nil
+}
+
+func (e *Election) client() redis.UniversalClient {
+	if e.options.Client != nil {
+		return e.options.Client
+	}
+	return GetRedisClient()
+}
+
+// ID 当前实例的token，当选后会写到Key里
+func (e *Election) ID() string {
+	return e.id
+}
+
+// Leader 当前实例是不是leader
+func (e *Election) Leader() bool {
+	return atomic.LoadInt32(&e.leader) == 1
+}
+
+// CurrentLeader 返回当前leader的ID，没有leader时返回空字符串
+func (e *Election) CurrentLeader(ctx context.Context) (string, error) {
+	cli := e.client()
+	if cli == nil {
+		return "", ErrClientNotInitialized
+	}
+	id, err := cli.Get(ctx, e.options.Key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return id, err
+}
+
+// Campaign 参与选举，阻塞直到ctx被取消，期间失去leader身份后会继续竞选
+// ctx取消时如果是leader会主动释放，其他实例不需要等租期过期
+func (e *Election) Campaign(ctx context.Context) error {
+	cli := e.client()
+	if cli == nil {
+		return ErrClientNotInitialized
+	}
+	if !atomic.CompareAndSwapInt32(&e.campaigning, 0, 1) {
+		return ErrCampaignRunning
+	}
+	defer atomic.StoreInt32(&e.campaigning, 0)
+
+	for {
+		sent := time.Now()
+		success, err := cli.SetNX(ctx, e.options.Key, e.id, e.options.TTL).Result()
+		if err == nil && success {
+			e.lead(ctx, cli, sent)
+		} else if err != nil && ctx.Err() == nil {
+			logrus.Warn("[REDIS][ELECTION] campaign ", e.options.Key, " error! ", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(e.options.RetryInterval):
+		}
+	}
+}
+
+// 租期从命令发出的时间算起，提前TTL/10退位，留出时钟误差的余量
+func (e *Election) leaseDeadline(sent time.Time) time.Time {
+	return sent.Add(e.options.TTL - e.options.TTL/10)
+}
+
+// 当选后定时续期，直到ctx取消、续期失败或者租期到期，sent是SET NX发出的时间
+func (e *Election) lead(ctx context.Context, cli redis.UniversalClient, sent time.Time) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	atomic.StoreInt32(&e.leader, 1)
+	logrus.Info("[REDIS][ELECTION] elected: ", e.options.Key, " ", e.id)
+	if e.options.OnElected != nil {
+		e.options.OnElected(leaderCtx)
+	}
+	defer e.stepDown(cancel)
+
+	ticker := time.NewTicker(e.options.RenewInterval)
+	defer ticker.Stop()
+	deadline := e.leaseDeadline(sent)
+	// 不等下一次续期，租期一到就退位，避免和新的leader同时存在
+	expired := time.NewTimer(time.Until(deadline))
+	defer expired.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			releaseCtx, releaseCancel := context.WithTimeout(context.Background(), defaultTimeout)
+			defer releaseCancel()
+			err := releaseLockScript.Run(releaseCtx, cli, []string{e.options.Key}, e.id).Err()
+			if err != nil {
+				logrus.Warn("[REDIS][ELECTION] release ", e.options.Key, " error! ", err)
+			}
+			return
+		case <-expired.C:
+			logrus.Warn("[REDIS][ELECTION] lease of ", e.options.Key, " expired")
+			return
+		case <-ticker.C:
+			// 续期最多阻塞到租期结束
+			sent := time.Now()
+			renewCtx, renewCancel := context.WithDeadline(ctx, deadline)
+			renewed, err := renewLockScript.Run(renewCtx, cli, []string{e.options.Key}, e.id, e.options.TTL.Milliseconds()).Int()
+			renewCancel()
+			if err == nil && renewed == 0 {
+				logrus.Warn("[REDIS][ELECTION] lease of ", e.options.Key, " is taken by others")
+				return
+			}
+			if err != nil {
+				// 网络错误时继续重试，租期到期时由expired退位
+				logrus.Warn("[REDIS][ELECTION] renew ", e.options.Key, " error! ", err)
+				if !time.Now().Before(deadline) {
+					return
+				}
+				continue
+			}
+			deadline = e.leaseDeadline(sent)
+			if !expired.Stop() {
+				select {
+				case <-expired.C:
+				default:
+				}
+			}
+			expired.Reset(time.Until(deadline))
+		}
+	}
+}
+
+func (e *Election) stepDown(cancel context.CancelFunc) {
+	cancel()
+	atomic.StoreInt32(&e.leader, 0)
+	logrus.Info("[REDIS][ELECTION] revoked: ", e.options.Key, " ", e.id)
+	if e.options.OnRevoked != nil {
+		e.options.OnRevoked()
+	}
+}
@@ -0,0 +1,144 @@
+package g_rediscache
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cond()
+}
+
+func TestElection(t *testing.T) {
+	RedisTestSetup()
+	key := "testtest_election_" + strconv.Itoa(getRand())
+	var elected, revoked int32
+	newElection := func() *Election {
+		e, err := NewElection(ElectionOptions{
+			Key:           key,
+			TTL:           time.Second,
+			RenewInterval: 100 * time.Millisecond,
+			RetryInterval: 50 * time.Millisecond,
+			OnElected: func(ctx context.Context) {
+				atomic.AddInt32(&elected, 1)
+			},
+			OnRevoked: func() {
+				atomic.AddInt32(&revoked, 1)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	e1, e2 := newElection(), newElection()
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	done1 := make(chan error)
+	go func() { done1 <- e1.Campaign(ctx1) }()
+	if !waitFor(time.Second, e1.Leader) {
+		t.Fatal("1. e1 must be elected")
+	}
+	if err := e1.Campaign(ctx1); err != ErrCampaignRunning {
+		t.Fatal("1. campaign twice must fail", err)
+	}
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	go e2.Campaign(ctx2)
+	// 续期保证租期过了之后e1仍然是leader
+	time.Sleep(1500 * time.Millisecond)
+	if !e1.Leader() || e2.Leader() {
+		t.Fatal("2. only e1 can be leader", e1.Leader(), e2.Leader())
+	}
+	if id, _ := e1.CurrentLeader(context.Background()); id != e1.ID() {
+		t.Fatal("2. current leader must be e1", id)
+	}
+
+	// e1退出后主动释放，e2不用等租期过期
+	cancel1()
+	<-done1
+	if e1.Leader() || atomic.LoadInt32(&revoked) != 1 {
+		t.Fatal("3. e1 must step down", revoked)
+	}
+	if !waitFor(500*time.Millisecond, e2.Leader) {
+		t.Fatal("3. e2 must be elected")
+	}
+
+	// 租期被抢走
+	GetRedisClient().Set(context.Background(), key, "other", time.Minute)
+	if !waitFor(time.Second, func() bool { return !e2.Leader() }) {
+		t.Fatal("4. e2 must step down when lease is taken")
+	}
+	if atomic.LoadInt32(&elected) != 2 || atomic.LoadInt32(&revoked) != 2 {
+		t.Fatal("4. callbacks not expected", elected, revoked)
+	}
+}
+
+// 续期的时候模拟网络错误
+type failingHook struct {
+	fail int32
+}
+
+func (h *failingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if atomic.LoadInt32(&h.fail) == 1 {
+		return ctx, errors.New("network error")
+	}
+	return ctx, nil
+}
+
+func (h *failingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *failingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return h.BeforeProcess(ctx, nil)
+}
+
+func (h *failingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func TestElection_RenewError(t *testing.T) {
+	RedisTestSetup()
+	key := "testtest_election_" + strconv.Itoa(getRand())
+	hook := &failingHook{}
+	cli := redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+	cli.AddHook(hook)
+	defer cli.Close()
+	e, err := NewElection(ElectionOptions{
+		Key:           key,
+		TTL:           time.Second,
+		RenewInterval: 300 * time.Millisecond,
+		Client:        cli,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Campaign(ctx)
+	if !waitFor(time.Second, e.Leader) {
+		t.Fatal("1. must be elected")
+	}
+	atomic.StoreInt32(&hook.fail, 1)
+	// 续期失败时要在redis里的租期过期之前退位，不能等到下一次续期
+	if !waitFor(2*time.Second, func() bool { return !e.Leader() }) {
+		t.Fatal("2. must step down when lease expires")
+	}
+	if GetRedisClient().PTTL(context.Background(), key).Val() <= 0 {
+		t.Fatal("2. must step down before the lease in redis expires")
+	}
+}
@@ -3,22 +3,46 @@ package g_rediscache
 import (
 	"context"
 	"errors"
+	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 	"math/rand"
 	"time"
 )
 
+// 只有value等于加锁时写入的token才删除，避免锁过期后删掉别人的锁
+var releaseLockScript = redis.NewScript(`
+if redis.call('get', KEYS[1]) == ARGV[1] then
+	return redis.call('del', KEYS[1])
+else
+	return 0
+end
+`)
+
 type GlobalLockOptions struct {
 	Ctx     context.Context
 	Key     string
 	Timeout time.Duration
 	Expire  time.Duration
+
+	// 为空时使用InitRedisClient设置的默认client
+	Client redis.UniversalClient
+}
+
+func (o *GlobalLockOptions) client() redis.UniversalClient {
+	if o.Client != nil {
+		return o.Client
+	}
+	return GetRedisClient()
 }
 
 func GlobalLock(options *GlobalLockOptions, fallback func() (interface{}, error)) (interface{}, error) {
 	if options.Key == "" {
 		return nil, errors.New("key must not be empty")
 	}
+	cli := options.client()
+	if cli == nil {
+		return nil, ErrClientNotInitialized
+	}
 
 	if options.Expire == 0 {
 		options.Expire = defaultExpire
@@ -26,12 +50,10 @@ func GlobalLock(options *GlobalLockOptions, fallback func() (interface{}, error)
 	startTime := time.Now()
 	for {
 		unique := NewObjectID().Hex()
-		success, err := GetRedisClient().SetNX(options.Ctx, options.Key,
+		success, err := cli.SetNX(options.Ctx, options.Key,
 			unique, options.Expire).Result()
 		if err == nil && success {
-			defer GetRedisClient().Eval(options.Ctx,
-				"if redis.call('get', KEYS[1]) == ARGV[1] then return redis.call('del', KEYS[1]) else return 0 end",
-				[]string{options.Key}, unique)
+			defer releaseLockScript.Run(options.Ctx, cli, []string{options.Key}, unique)
 			logrus.Debug("add lock success: ", options.Key)
 			return fallback()
 		}
@@ -61,6 +83,11 @@ func (p *GLockProxy) WithExpire(expire time.Duration) *GLockProxy {
 	return p
 }
 
+func (p *GLockProxy) WithClient(cli redis.UniversalClient) *GLockProxy {
+	p.options.Client = cli
+	return p
+}
+
 func (p *GLockProxy) Then(f func() (interface{}, error)) (interface{}, error) {
 	return GlobalLock(&p.options, f)
 }
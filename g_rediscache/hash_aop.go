@@ -2,8 +2,8 @@ package g_rediscache
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 	"reflect"
 	"time"
@@ -34,80 +34,149 @@ func (o HashOptions) validate() error {
 	return nil
 }
 
-func HashAop(options *HashOptions, fallback func() ([]interface{}, error)) ([]interface{}, bool, error) {
+// 只在key新建时设置过期时间，部分回填不能缩短或者延长已有field的过期时间
+var fillHashScript = redis.NewScript(`
+local existed = redis.call('exists', KEYS[1])
+for i = 2, #ARGV, 2 do
+	redis.call('hset', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+if existed == 0 then
+	redis.call('pexpire', KEYS[1], ARGV[1])
+end
+`)
+
+// HashAop 只把cache里缺失的field交给fallback，fallback没有返回的field视为不存在，
+// 如果设置了EmptyExpires会用EmptyFlag占位，避免每次请求都回源
+// 返回结果按Fields的顺序排列，不存在的field不会出现在结果里
+func HashAop(options *HashOptions, fallback func(missFields []string) ([]interface{}, error)) ([]interface{}, bool, error) {
 	err := options.validate()
 	if err != nil {
 		return nil, false, err
 	}
-	cacheVs, err := GetRedisClient().
-		HMGet(options.Ctx, options.Key, options.Fields...).Result()
-	var result []interface{}
-	shouldCallback := len(cacheVs) == 0
-	if !shouldCallback {
-		for _, cacheV := range cacheVs {
-			if cacheV == nil {
-				shouldCallback = true
-				logrus.Warn("[REDIS][HASH] key ", options.Key, " has nil value, values", cacheVs)
-				break
-			}
-			rtv := reflect.New(options.Rt)
-			rv := rtv.Interface()
-			err := json.Unmarshal([]byte(cacheV.(string)), rv)
-			if err != nil {
-				return nil, false, err
-			}
-			result = append(result, reflect.ValueOf(rv).Elem().Interface())
+	key := options.cacheKey()
+	metric := startMetric(aopHash, options.Key)
+	defer metric.done()
+	if !options.bloomAllow(options.Key) {
+		metric.event(EventBloomReject)
+		return nil, false, nil
+	}
+	options.touchHotKey(key)
+	values := make([]interface{}, len(options.Fields))
+	var missFields []string
+	missIndex := make(map[string]int)
+	cacheVs, err := options.client().
+		HMGet(options.Ctx, key, options.Fields...).Result()
+	if err != nil {
+		logrus.Warn("[REDIS][HASH] key ", key, " hmget error, maybe load all from db! ", err)
+		cacheVs = make([]interface{}, len(options.Fields))
+	}
+	for i, cacheV := range cacheVs {
+		if cacheV == nil {
+			metric.event(EventMiss)
+			missFields = append(missFields, options.Fields[i])
+			missIndex[options.Fields[i]] = i
+			continue
+		}
+		if cacheV == EmptyFlag {
+			metric.event(EventEmptyHit)
+			continue
 		}
-		if !shouldCallback {
-			return result, true, nil
+		v, err := decodeCacheValue(cacheV.(string), options.Rt)
+		if err != nil {
+			// 反序列化失败的field按miss处理，回填时覆盖
+			metric.event(EventDecodeError)
+			logrus.Warn("[REDIS][HASH] key ", key, " field ", options.Fields[i], " decode error! ", err)
+			missFields = append(missFields, options.Fields[i])
+			missIndex[options.Fields[i]] = i
+			continue
 		}
+		metric.event(EventHit)
+		values[i] = v
 	}
+	if len(missFields) == 0 {
+		return compactHashValues(values), true, nil
+	}
+	logrus.Warn("[REDIS][HASH] key ", key, " missed fields ", missFields, ", maybe load from db!")
 
-	result, err = fallback()
+	items, err := fallback(missFields)
 	if err != nil {
+		metric.event(EventFallbackError)
 		return nil, false, err
 	}
 
 	// 回填
+	var fieldValues []interface{}
 	rewriteCount := 0
-	if result != nil && len(result) > 0 {
-		for _, item := range result {
-			cacheV, isEmpty, err := GetCacheValueItem(item)
-			if err != nil {
-				logrus.Warn("[REDIS][HASH] GetCacheValueItem error!", err)
-				continue
-			}
-			// 看一下struct里面的作为field的Field是否有正确的值
-			fieldV := ""
-			if options.FieldAttr != "" {
-				iv := reflect.ValueOf(&item)
-				if reflect.TypeOf(item).Kind() == reflect.Struct {
-					ivf := iv.Elem().Elem().FieldByName(options.FieldAttr)
-					if ivf.IsValid() {
-						vv, success := Primary2String(ivf.Interface(), ivf.Kind())
-						if success {
-							fieldV = vv
-						}
-					}
-				}
-			}
-			if fieldV == "" {
-				logrus.Warn("[REDIS][HASH] key ", options.Key, " value ", item, " has not valid fieldValue!!!")
-			}
-			if !isEmpty && fieldV != "" {
-				GetRedisClient().HSet(options.Ctx, options.Key, fieldV, cacheV).Val()
-				rewriteCount++
-			}
+	for _, item := range items {
+		cacheV, isEmpty, err := GetCacheValueItem(item)
+		if err != nil {
+			logrus.Warn("[REDIS][HASH] GetCacheValueItem error!", err)
+			continue
+		}
+		fieldV := hashFieldValue(item, options.FieldAttr)
+		if fieldV == "" {
+			logrus.Warn("[REDIS][HASH] key ", key, " value ", item, " has not valid fieldValue!!!")
+			continue
+		}
+		i, ok := missIndex[fieldV]
+		if !ok || isEmpty {
+			continue
+		}
+		values[i] = item
+		delete(missIndex, fieldV)
+		fieldValues = append(fieldValues, fieldV, cacheV)
+		rewriteCount++
+	}
+	// 空值回填
+	if options.EmptyExpires > 0 {
+		for field := range missIndex {
+			fieldValues = append(fieldValues, field, EmptyFlag)
+		}
+	}
+	if len(fieldValues) > 0 {
+		expires := options.Expires
+		if expires == 0 {
+			expires = defaultExpire
 		}
-		if rewriteCount > 0 {
-			if options.Expires == 0 {
-				options.Expires = defaultExpire
-			}
-			GetRedisClient().Expire(options.Ctx, options.Key, options.Expires)
+		if rewriteCount == 0 {
+			expires = options.EmptyExpires
+		}
+		args := append([]interface{}{expires.Milliseconds()}, fieldValues...)
+		err := fillHashScript.Run(options.Ctx, options.client(), []string{key}, args...).Err()
+		if err != nil && err != redis.Nil {
+			metric.event(EventFillError)
+			logrus.Warn("[REDIS][HASH] key ", key, " rewrite cache error! ", err)
 		}
 	}
 
-	return result, false, nil
+	return compactHashValues(values), false, nil
+}
+
+// 看一下struct里面的作为field的Field是否有正确的值
+func hashFieldValue(item interface{}, fieldAttr string) string {
+	if item == nil || reflect.TypeOf(item).Kind() != reflect.Struct {
+		return ""
+	}
+	ivf := reflect.ValueOf(item).FieldByName(fieldAttr)
+	if !ivf.IsValid() {
+		return ""
+	}
+	vv, success := Primary2String(ivf.Interface(), ivf.Kind())
+	if !success {
+		return ""
+	}
+	return vv
+}
+
+// 去掉不存在的field，保持Fields的顺序
+func compactHashValues(values []interface{}) []interface{} {
+	var result []interface{}
+	for _, v := range values {
+		if v != nil {
+			result = append(result, v)
+		}
+	}
+	return result
 }
 
 type HashAopProxy struct {
@@ -124,7 +193,28 @@ func (p *HashAopProxy) WithEmptyExpires(emptyExpires time.Duration) *HashAopProx
 	return p
 }
 
-func (p *HashAopProxy) Then(f func() ([]interface{}, error)) ([]interface{}, bool, error) {
+func (p *HashAopProxy) WithClient(cli redis.UniversalClient) *HashAopProxy {
+	p.options.Client = cli
+	return p
+}
+
+func (p *HashAopProxy) WithBloom(bloom *BloomFilter) *HashAopProxy {
+	p.options.Bloom = bloom
+	return p
+}
+
+func (p *HashAopProxy) WithHotKey(detector *HotKeyDetector) *HashAopProxy {
+	p.options.HotKey = detector
+	return p
+}
+
+func (p *HashAopProxy) WithNamespace(namespace string, version int) *HashAopProxy {
+	p.options.Namespace = namespace
+	p.options.Version = version
+	return p
+}
+
+func (p *HashAopProxy) Then(f func(missFields []string) ([]interface{}, error)) ([]interface{}, bool, error) {
 	return HashAop(&p.options, f)
 }
 
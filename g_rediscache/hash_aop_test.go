@@ -14,7 +14,7 @@ func ExampleUseHashAop() {
 	RedisTestSetup()
 	beego.SetLevel(beego.LevelError)
 	cacheKey := "testtest_hash_" + strconv.Itoa(getRand())
-	bizFunc := func() (i []interface{}, e error) {
+	bizFunc := func(missFields []string) (i []interface{}, e error) {
 		var r []interface{}
 		r = append(r, User{Id: 1, Name: "name1"})
 		r = append(r, User{Id: 2, Name: "name2"})
@@ -36,6 +36,7 @@ func TestHashAop(t *testing.T) {
 	GetRedisClient().Del(context.Background(), cacheKey)
 
 	options := &HashOptions{}
+	options.Ctx = context.Background()
 	options.Key = cacheKey
 	options.Rt = reflect.TypeOf(User{})
 	options.Expires = 5 * time.Second
@@ -43,7 +44,7 @@ func TestHashAop(t *testing.T) {
 	options.FieldAttr = "Id"
 
 	// 第一次, cache里没有值，从fallback取到并回填
-	val, fromCache, err := HashAop(options, func() ([]interface{}, error) {
+	val, fromCache, err := HashAop(options, func(missFields []string) ([]interface{}, error) {
 		var r []interface{}
 		r = append(r, User{Id: 1, Name: "name1"})
 		r = append(r, User{Id: 2, Name: "name2"})
@@ -61,7 +62,7 @@ func TestHashAop(t *testing.T) {
 	}
 
 	// 第二次, cache有值，直接从cache里取值
-	val, fromCache, err = HashAop(options, func() ([]interface{}, error) {
+	val, fromCache, err = HashAop(options, func(missFields []string) ([]interface{}, error) {
 		var r []interface{}
 		r = append(r, User{Id: 1, Name: "name1"})
 		r = append(r, User{Id: 2, Name: "name2"})
@@ -80,3 +81,86 @@ func TestHashAop(t *testing.T) {
 	}
 
 }
+
+func TestHashAop_PartialMiss(t *testing.T) {
+	RedisTestSetup()
+	cacheKey := "testtest_hash_" + strconv.Itoa(getRand())
+
+	options := &HashOptions{}
+	options.Ctx = context.Background()
+	options.Key = cacheKey
+	options.Rt = reflect.TypeOf(User{})
+	options.Expires = 30 * time.Second
+	options.EmptyExpires = 10 * time.Second
+	options.FieldAttr = "Id"
+
+	options.Fields = []string{"1"}
+	_, _, err := HashAop(options, func(missFields []string) ([]interface{}, error) {
+		return []interface{}{User{Id: 1, Name: "name1"}}, nil
+	})
+	if err != nil {
+		t.Fatal("1. rewrite FAIL", err)
+	}
+
+	// 只有2和3需要回源，3不存在
+	options.Fields = []string{"3", "1", "2"}
+	val, fromCache, err := HashAop(options, func(missFields []string) ([]interface{}, error) {
+		if len(missFields) != 2 || missFields[0] != "3" || missFields[1] != "2" {
+			t.Fatal("2. missFields must be 3 and 2", missFields)
+		}
+		return []interface{}{User{Id: 2, Name: "name2"}}, nil
+	})
+	if err != nil || fromCache || len(val) != 2 || val[0].(User).Id != 1 || val[1].(User).Id != 2 {
+		t.Fatal("2. partial miss FAIL", err, val)
+	}
+	cacheV, _ := GetRedisClient().HGet(context.Background(), cacheKey, "3").Result()
+	if cacheV != EmptyFlag {
+		t.Fatal("2.1 empty field FAIL", cacheV)
+	}
+
+	// 第三次全部命中，不存在的field也不再回源
+	val, fromCache, err = HashAop(options, func(missFields []string) ([]interface{}, error) {
+		t.Fatal("3. fallback must not be called", missFields)
+		return nil, nil
+	})
+	if err != nil || !fromCache || len(val) != 2 {
+		t.Fatal("3. must be from cache FAIL", err)
+	}
+}
+
+func TestHashAop_KeepTTL(t *testing.T) {
+	RedisTestSetup()
+	cacheKey := "testtest_hash_" + strconv.Itoa(getRand())
+
+	options := &HashOptions{}
+	options.Ctx = context.Background()
+	options.Key = cacheKey
+	options.Rt = reflect.TypeOf(User{})
+	options.Expires = time.Hour
+	options.EmptyExpires = 2 * time.Second
+	options.FieldAttr = "Id"
+
+	options.Fields = []string{"1"}
+	_, _, err := HashAop(options, func(missFields []string) ([]interface{}, error) {
+		return []interface{}{User{Id: 1, Name: "name1"}}, nil
+	})
+	if err != nil {
+		t.Fatal("1. rewrite FAIL", err)
+	}
+	ttl := GetRedisClient().PTTL(context.Background(), cacheKey).Val()
+	if ttl <= 59*time.Minute {
+		t.Fatal("1. new hash must expire in Expires FAIL", ttl)
+	}
+
+	// 只回填了空值，不能把整个hash的过期时间改成EmptyExpires
+	options.Fields = []string{"2"}
+	_, _, err = HashAop(options, func(missFields []string) ([]interface{}, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal("2. rewrite FAIL", err)
+	}
+	if ttl2 := GetRedisClient().PTTL(context.Background(), cacheKey).Val(); ttl2 > ttl || ttl2 <= 59*time.Minute {
+		t.Fatal("2. partial fill must not change the TTL FAIL", ttl, ttl2)
+	}
+}
@@ -0,0 +1,187 @@
+package g_rediscache
+
+import (
+	"github.com/zsyu9779/myUtil/hash"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultHotKeyWindow    = time.Second
+	defaultHotKeyThreshold = 1000
+	defaultSketchWidth     = 2048
+	defaultSketchDepth     = 4
+)
+
+type HotKeyOptions struct {
+	// 统计窗口，默认1s，每个窗口重新计数
+	Window time.Duration
+	// 一个窗口内访问次数达到Threshold认为是热key，默认1000
+	Threshold uint64
+	// 每SampleRate次访问统计一次，计数时乘上SampleRate，默认1即每次都统计
+	SampleRate uint32
+	// count-min sketch的宽度和深度，默认2048 * 4，宽度越大误差越小
+	Width uint32
+	Depth uint32
+
+	// 热key放进本地缓存的时间，为0时只上报不提升
+	// 只有SimpleAop和BatchAop会提升，本地缓存的值会被所有调用方共享，不要修改它
+	LocalTTL time.Duration
+	// 每个窗口内第一次发现热key时回调，不要在回调里做耗时操作
+	OnHot func(key string, count uint64)
+}
+
+// HotKeyDetector 用count-min sketch按窗口统计key的访问次数，发现热key后上报，
+// 并且可以把热key提升到本地缓存，直到它冷却下来（本地缓存过期后不再是热key）
+type HotKeyDetector struct {
+	options HotKeyOptions
+	sketch  atomic.Pointer[hotKeySketch]
+	touches uint32
+	local   sync.Map
+}
+
+type hotKeySketch struct {
+	start    time.Time
+	counters []uint32
+	reported sync.Map
+}
+
+type localEntry struct {
+	value    interface{}
+	expireAt time.Time
+}
+
+func NewHotKeyDetector(options HotKeyOptions) *HotKeyDetector {
+	if options.Window <= 0 {
+		options.Window = defaultHotKeyWindow
+	}
+	if options.Threshold == 0 {
+		options.Threshold = defaultHotKeyThreshold
+	}
+	if options.SampleRate == 0 {
+		options.SampleRate = 1
+	}
+	if options.Width == 0 {
+		options.Width = defaultSketchWidth
+	}
+	if options.Depth == 0 {
+		options.Depth = defaultSketchDepth
+	}
+	d := &HotKeyDetector{options: options}
+	d.sketch.Store(d.newSketch(time.Now()))
+	return d
+}
+
+func (d *HotKeyDetector) newSketch(start time.Time) *hotKeySketch {
+	return &hotKeySketch{start: start, counters: make([]uint32, d.options.Width*d.options.Depth)}
+}
+
+// 窗口到期时换一个新的sketch，并清理过期的本地缓存
+func (d *HotKeyDetector) currentSketch() *hotKeySketch {
+	s := d.sketch.Load()
+	now := time.Now()
+	if now.Sub(s.start) < d.options.Window {
+		return s
+	}
+	if d.sketch.CompareAndSwap(s, d.newSketch(now)) {
+		d.local.Range(func(key, value interface{}) bool {
+			if now.After(value.(*localEntry).expireAt) {
+				d.local.Delete(key)
+			}
+			return true
+		})
+	}
+	return d.sketch.Load()
+}
+
+// Touch 记录一次访问，返回当前窗口内的估计访问次数，以及是否是热key
+func (d *HotKeyDetector) Touch(key string) (uint64, bool) {
+	s := d.currentSketch()
+	if d.options.SampleRate > 1 && atomic.AddUint32(&d.touches, 1)%d.options.SampleRate != 0 {
+		count := d.estimate(s, key)
+		return count, count >= d.options.Threshold
+	}
+	var count uint64
+	b := []byte(key)
+	for i := uint32(0); i < d.options.Depth; i++ {
+		idx := i*d.options.Width + hash.Murmurhash3(b, i)%d.options.Width
+		c := uint64(atomic.AddUint32(&s.counters[idx], d.options.SampleRate))
+		if i == 0 || c < count {
+			count = c
+		}
+	}
+	if count < d.options.Threshold {
+		return count, false
+	}
+	if _, loaded := s.reported.LoadOrStore(key, struct{}{}); !loaded && d.options.OnHot != nil {
+		d.options.OnHot(key, count)
+	}
+	return count, true
+}
+
+func (d *HotKeyDetector) estimate(s *hotKeySketch, key string) uint64 {
+	var count uint64
+	b := []byte(key)
+	for i := uint32(0); i < d.options.Depth; i++ {
+		idx := i*d.options.Width + hash.Murmurhash3(b, i)%d.options.Width
+		c := uint64(atomic.LoadUint32(&s.counters[idx]))
+		if i == 0 || c < count {
+			count = c
+		}
+	}
+	return count
+}
+
+// HotKeys 返回当前窗口内已经发现的热key
+func (d *HotKeyDetector) HotKeys() []string {
+	var keys []string
+	d.currentSketch().reported.Range(func(key, value interface{}) bool {
+		keys = append(keys, key.(string))
+		return true
+	})
+	return keys
+}
+
+func (d *HotKeyDetector) getLocal(key string) (interface{}, bool) {
+	v, ok := d.local.Load(key)
+	if !ok {
+		return nil, false
+	}
+	entry := v.(*localEntry)
+	if time.Now().After(entry.expireAt) {
+		d.local.Delete(key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (d *HotKeyDetector) putLocal(key string, value interface{}) {
+	d.local.Store(key, &localEntry{value: value, expireAt: time.Now().Add(d.options.LocalTTL)})
+}
+
+// 统计一次访问，第二个返回值表示命中了本地缓存，第三个返回值表示key是热key，调用方需要把取到的值提升到本地缓存
+func (o *Options) hotKeyGet(key string) (interface{}, bool, bool) {
+	if o.HotKey == nil {
+		return nil, false, false
+	}
+	_, hot := o.HotKey.Touch(key)
+	if !hot || o.HotKey.options.LocalTTL <= 0 {
+		return nil, false, false
+	}
+	v, ok := o.HotKey.getLocal(key)
+	return v, ok, true
+}
+
+func (o *Options) hotKeyPut(key string, value interface{}) {
+	if value != nil {
+		o.HotKey.putLocal(key, value)
+	}
+}
+
+// 只统计访问次数，用于没有本地提升的AOP
+func (o *Options) touchHotKey(key string) {
+	if o.HotKey != nil {
+		o.HotKey.Touch(key)
+	}
+}
@@ -0,0 +1,97 @@
+package g_rediscache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHotKeyDetector(t *testing.T) {
+	var reported []string
+	d := NewHotKeyDetector(HotKeyOptions{
+		Window:    200 * time.Millisecond,
+		Threshold: 100,
+		OnHot: func(key string, count uint64) {
+			reported = append(reported, key)
+		},
+	})
+	for i := 0; i < 99; i++ {
+		if _, hot := d.Touch("hot"); hot {
+			t.Fatal("must not be hot before threshold")
+		}
+		d.Touch("cold_" + strconv.Itoa(i))
+	}
+	for i := 0; i < 10; i++ {
+		if _, hot := d.Touch("hot"); !hot {
+			t.Fatal("must be hot after threshold")
+		}
+	}
+	if len(reported) != 1 || reported[0] != "hot" {
+		t.Fatal("hot key must be reported once per window", reported)
+	}
+	if keys := d.HotKeys(); len(keys) != 1 || keys[0] != "hot" {
+		t.Fatal("hot keys not expected", keys)
+	}
+
+	// 新的窗口重新计数
+	time.Sleep(200 * time.Millisecond)
+	if count, hot := d.Touch("hot"); hot || count != 1 {
+		t.Fatal("must cool down in new window", count)
+	}
+}
+
+func TestHotKeyDetector_Sample(t *testing.T) {
+	d := NewHotKeyDetector(HotKeyOptions{Threshold: 1000, SampleRate: 10})
+	for i := 0; i < 1000; i++ {
+		d.Touch("hot")
+	}
+	if count, hot := d.Touch("hot"); !hot || count != 1000 {
+		t.Fatal("sampled count not expected", count)
+	}
+}
+
+func TestHotKey_LocalPromotion(t *testing.T) {
+	RedisTestSetup()
+	cacheKey := "testtest_hotkey_" + strconv.Itoa(getRand())
+	d := NewHotKeyDetector(HotKeyOptions{Threshold: 3, LocalTTL: time.Second})
+	calls := 0
+	get := func() (interface{}, bool, error) {
+		return UseSimpleAop(context.Background(), cacheKey, reflect.TypeOf(User{})).WithHotKey(d).WithExpires(30 * time.Second).Then(func() (interface{}, error) {
+			calls++
+			return User{Id: 1}, nil
+		})
+	}
+	for i := 0; i < 4; i++ {
+		if _, _, err := get(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 已经提升到本地缓存，删掉redis里的值也能取到
+	GetRedisClient().Del(context.Background(), cacheKey)
+	v, fromCache, err := get()
+	if err != nil || !fromCache || v.(User).Id != 1 || calls != 1 {
+		t.Fatal("local promotion FAIL", err, v, calls)
+	}
+
+	// BatchAop 同样会提升
+	keyTpl := cacheKey + ":%v"
+	for i := 0; i < 4; i++ {
+		_, _, err := UseBatchAop(context.Background(), keyTpl, reflect.TypeOf(User{}), []interface{}{1}).WithHotKey(d).Then(func(missIds []interface{}) (map[interface{}]interface{}, error) {
+			return map[interface{}]interface{}{1: User{Id: 1}}, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	GetRedisClient().Del(context.Background(), fmt.Sprintf(keyTpl, 1))
+	val, fromCache, err := UseBatchAop(context.Background(), keyTpl, reflect.TypeOf(User{}), []interface{}{1}).WithHotKey(d).Then(func(missIds []interface{}) (map[interface{}]interface{}, error) {
+		t.Fatal("fallback must not be called")
+		return nil, nil
+	})
+	if err != nil || !fromCache || len(val) != 1 {
+		t.Fatal("batch local promotion FAIL", err, val)
+	}
+}
@@ -0,0 +1,217 @@
+package g_rediscache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	idempotentProcessing   = "###processing###"
+	defaultIdempotentPoll  = 50 * time.Millisecond
+	defaultProcessingLease = 30 * time.Second
+)
+
+// 只有标记还是自己写入的token时才写入结果，fn执行超过处理中标记的有效期时放弃写入
+var storeIdempotentScript = redis.NewScript(`
+if redis.call('get', KEYS[1]) == ARGV[1] then
+	return redis.call('set', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+	return 0
+end
+`)
+
+// ErrIdempotentConflict 相同key的请求正在处理中
+var ErrIdempotentConflict = errors.New("idempotent request is in progress")
+
+type idempotentOptions struct {
+	processingTTL time.Duration
+	wait          time.Duration
+	waitInterval  time.Duration
+	retryOnError  bool
+	client        redis.UniversalClient
+}
+
+type IdempotentOption func(o *idempotentOptions)
+
+// IdempotentProcessingTTL 处理中标记的有效期，默认30s，fn执行期间每TTL/3续期一次，
+// 进程在fn执行期间崩溃时，过期后其他请求才能重新执行
+func IdempotentProcessingTTL(ttl time.Duration) IdempotentOption {
+	return func(o *idempotentOptions) {
+		o.processingTTL = ttl
+	}
+}
+
+// IdempotentWait 相同key的请求正在处理时最多等待timeout，默认不等待直接返回ErrIdempotentConflict
+func IdempotentWait(timeout time.Duration) IdempotentOption {
+	return func(o *idempotentOptions) {
+		o.wait = timeout
+	}
+}
+
+// IdempotentRetryOnError fn返回错误时不保存结果，之后相同key的请求会重新执行
+func IdempotentRetryOnError() IdempotentOption {
+	return func(o *idempotentOptions) {
+		o.retryOnError = true
+	}
+}
+
+func IdempotentClient(cli redis.UniversalClient) IdempotentOption {
+	return func(o *idempotentOptions) {
+		o.client = cli
+	}
+}
+
+type idempotentRecord struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Err    string          `json:"err,omitempty"`
+}
+
+// Idempotent 保证相同key的fn在ttl内只执行一次，重复的请求直接返回第一次执行保存的结果或者错误
+//
+//	order, err := Idempotent(ctx, "pay_callback:"+tradeNo, 24*time.Hour, func(ctx context.Context) (*Order, error) {
+//		return dao.PayOrder(ctx, tradeNo)
+//	}, IdempotentWait(5*time.Second))
+//
+// 结果用json序列化保存，重复请求拿到的是反序列化后的副本；保存的错误只保留错误信息
+func Idempotent[R any](ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) (R, error), opts ...IdempotentOption) (R, error) {
+	var zero R
+	if key == "" {
+		return zero, errors.New("key must not be empty")
+	}
+	o := &idempotentOptions{processingTTL: defaultProcessingLease, waitInterval: defaultIdempotentPoll}
+	for _, opt := range opts {
+		opt(o)
+	}
+	cli := o.client
+	if cli == nil {
+		cli = GetRedisClient()
+	}
+	if cli == nil {
+		return zero, ErrClientNotInitialized
+	}
+	if ttl <= 0 {
+		ttl = defaultExpire
+	}
+
+	token := idempotentProcessing + NewObjectID().Hex()
+	waitDeadline := time.Now().Add(o.wait)
+	for {
+		success, err := cli.SetNX(ctx, key, token, o.processingTTL).Result()
+		if err != nil {
+			return zero, err
+		}
+		if success {
+			return runIdempotent(ctx, cli, key, token, ttl, o, fn)
+		}
+		cacheV, err := cli.Get(ctx, key).Result()
+		if err == redis.Nil {
+			// 标记刚好过期或者被删除，重新抢
+			continue
+		}
+		if err != nil {
+			return zero, err
+		}
+		if !strings.HasPrefix(cacheV, idempotentProcessing) {
+			return decodeIdempotentRecord[R](cacheV)
+		}
+		if !time.Now().Before(waitDeadline) {
+			return zero, ErrIdempotentConflict
+		}
+		select {
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		case <-time.After(o.waitInterval):
+		}
+	}
+}
+
+func runIdempotent[R any](ctx context.Context, cli redis.UniversalClient, key, token string, ttl time.Duration,
+	o *idempotentOptions, fn func(ctx context.Context) (R, error)) (R, error) {
+	stopRenew := renewIdempotentMarker(cli, key, token, o.processingTTL)
+	result, fnErr := fn(ctx)
+	stopRenew()
+	// fn执行完后ctx可能已经取消，结果仍然要保存
+	storeCtx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if fnErr != nil && o.retryOnError {
+		if err := releaseLockScript.Run(storeCtx, cli, []string{key}, token).Err(); err != nil {
+			logrus.Warn("[REDIS][IDEMPOTENT] release ", key, " error! ", err)
+		}
+		return result, fnErr
+	}
+	record := idempotentRecord{}
+	if fnErr != nil {
+		record.Err = fnErr.Error()
+	} else if data, err := json.Marshal(result); err != nil {
+		// fn已经执行过了，保存错误记录，重复的请求拿到同样的错误，不能再执行fn
+		logrus.Warn("[REDIS][IDEMPOTENT] marshal result of ", key, " error! ", err)
+		fnErr = fmt.Errorf("marshal idempotent result: %w", err)
+		record.Err = fnErr.Error()
+	} else {
+		record.Result = data
+	}
+	data, _ := json.Marshal(record)
+	stored, err := storeIdempotentScript.Run(storeCtx, cli, []string{key}, token, string(data), ttl.Milliseconds()).Result()
+	if err != nil && err != redis.Nil {
+		logrus.Warn("[REDIS][IDEMPOTENT] store result of ", key, " error! ", err)
+	} else if stored == int64(0) {
+		logrus.Warn("[REDIS][IDEMPOTENT] processing marker of ", key, " expired before fn finished")
+	}
+	return result, fnErr
+}
+
+// fn执行期间和Election一样比较token续期处理中标记，fn执行超过processingTTL时重复的请求也不会再执行fn
+// 返回的函数停止续期，并等待正在进行的续期结束
+func renewIdempotentMarker(cli redis.UniversalClient, key, token string, processingTTL time.Duration) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(processingTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), processingTTL/3)
+				renewed, err := renewLockScript.Run(ctx, cli, []string{key}, token, processingTTL.Milliseconds()).Int()
+				cancel()
+				if err != nil {
+					logrus.Warn("[REDIS][IDEMPOTENT] renew processing marker of ", key, " error! ", err)
+				} else if renewed == 0 {
+					logrus.Warn("[REDIS][IDEMPOTENT] processing marker of ", key, " is lost")
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+func decodeIdempotentRecord[R any](cacheV string) (R, error) {
+	var result R
+	var record idempotentRecord
+	if err := json.Unmarshal([]byte(cacheV), &record); err != nil {
+		return result, err
+	}
+	if record.Err != "" {
+		return result, errors.New(record.Err)
+	}
+	if len(record.Result) > 0 {
+		if err := json.Unmarshal(record.Result, &result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,126 @@
+package g_rediscache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotent(t *testing.T) {
+	RedisTestSetup()
+	ctx := context.Background()
+	key := "testtest_idempotent_" + strconv.Itoa(getRand())
+	var calls int32
+	fn := func(ctx context.Context) (*User, error) {
+		atomic.AddInt32(&calls, 1)
+		return &User{Id: 1, Name: "name"}, nil
+	}
+	for i := 0; i < 3; i++ {
+		u, err := Idempotent(ctx, key, time.Minute, fn)
+		if err != nil || u == nil || u.Name != "name" {
+			t.Fatal("1. idempotent FAIL", err, u)
+		}
+	}
+	if calls != 1 {
+		t.Fatal("1. fn must be called once", calls)
+	}
+
+	// 错误也会保存
+	errKey := key + "_err"
+	for i := 0; i < 3; i++ {
+		_, err := Idempotent(ctx, errKey, time.Minute, func(ctx context.Context) (int, error) {
+			atomic.AddInt32(&calls, 1)
+			return 0, errors.New("insufficient balance")
+		})
+		if err == nil || err.Error() != "insufficient balance" {
+			t.Fatal("2. stored error not expected", err)
+		}
+	}
+	if calls != 2 {
+		t.Fatal("2. fn must be called once", calls)
+	}
+
+	// 失败后允许重试
+	retryKey := key + "_retry"
+	for i := 0; i < 2; i++ {
+		Idempotent(ctx, retryKey, time.Minute, func(ctx context.Context) (int, error) {
+			atomic.AddInt32(&calls, 1)
+			return 0, errors.New("timeout")
+		}, IdempotentRetryOnError())
+	}
+	if calls != 4 {
+		t.Fatal("3. fn must be retried", calls)
+	}
+
+	// 结果序列化失败时保存错误，fn不能再执行
+	marshalKey := key + "_marshal"
+	for i := 0; i < 2; i++ {
+		_, err := Idempotent(ctx, marshalKey, time.Minute, func(ctx context.Context) (chan int, error) {
+			atomic.AddInt32(&calls, 1)
+			return make(chan int), nil
+		})
+		if err == nil {
+			t.Fatal("4. marshal error must be returned")
+		}
+	}
+	if calls != 5 {
+		t.Fatal("4. fn must be called once", calls)
+	}
+}
+
+func TestIdempotent_Concurrent(t *testing.T) {
+	RedisTestSetup()
+	ctx := context.Background()
+	key := "testtest_idempotent_" + strconv.Itoa(getRand())
+	started := make(chan struct{})
+	go Idempotent(ctx, key, time.Minute, func(ctx context.Context) (string, error) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		return "ok", nil
+	})
+	<-started
+
+	fn := func(ctx context.Context) (string, error) {
+		return "duplicated", nil
+	}
+	_, err := Idempotent(ctx, key, time.Minute, fn)
+	if err != ErrIdempotentConflict {
+		t.Fatal("1. concurrent duplicate must conflict", err)
+	}
+	res, err := Idempotent(ctx, key, time.Minute, fn, IdempotentWait(time.Second))
+	if err != nil || res != "ok" {
+		t.Fatal("2. waiting duplicate must get stored result", err, res)
+	}
+}
+
+func TestIdempotent_LongRunning(t *testing.T) {
+	RedisTestSetup()
+	ctx := context.Background()
+	key := "testtest_idempotent_" + strconv.Itoa(getRand())
+	var calls int32
+	fn := func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(500 * time.Millisecond)
+		return "ok", nil
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Idempotent(ctx, key, time.Minute, fn, IdempotentProcessingTTL(150*time.Millisecond))
+	}()
+	// fn执行超过processingTTL，处理中标记续期了，重复的请求不能再执行fn
+	time.Sleep(300 * time.Millisecond)
+	if _, err := Idempotent(ctx, key, time.Minute, fn); err != ErrIdempotentConflict {
+		t.Fatal("1. duplicate must conflict while fn is running", err)
+	}
+	<-done
+	if res, err := Idempotent(ctx, key, time.Minute, fn); err != nil || res != "ok" {
+		t.Fatal("2. duplicate must get stored result", err, res)
+	}
+	if calls != 1 {
+		t.Fatal("3. fn must be called once", calls)
+	}
+}
@@ -2,8 +2,7 @@ package g_rediscache
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 	"reflect"
 	"time"
@@ -21,75 +20,92 @@ func ListAop(options *ListOptions, fallback func() ([]interface{}, error)) ([]in
 	if err != nil {
 		return nil, false, err
 	}
-	cacheVs, err := GetRedisClient().LRange(options.Ctx, options.Key, options.Start, options.Stop).Result()
+	key := options.cacheKey()
+	metric := startMetric(aopList, options.Key)
+	defer metric.done()
+	if !options.bloomAllow(options.Key) {
+		metric.event(EventBloomReject)
+		return nil, false, nil
+	}
+	options.touchHotKey(key)
+	cacheVs, err := options.client().LRange(options.Ctx, key, options.Start, options.Stop).Result()
 	var result []interface{}
 	// 从cache里取到值
 	if len(cacheVs) > 0 {
 		if len(cacheVs) == 1 && cacheVs[0] == EmptyFlag {
+			metric.event(EventEmptyHit)
 			return result, true, nil
 		}
+		var decodeErr error
 		for _, cacheV := range cacheVs {
 			if cacheV == EmptyFlag {
 				continue
 			}
-			rtv := reflect.New(options.Rt)
-			rv := rtv.Interface()
-			err := json.Unmarshal([]byte(cacheV), rv)
+			v, err := decodeCacheValue(cacheV, options.Rt)
 			if err != nil {
-				return nil, false, err
+				decodeErr = err
+				break
 			}
-			logrus.Info("[Reflect] Value.", rv)
-			result = append(result, reflect.ValueOf(rv).Elem().Interface())
-			logrus.Info("[Reflect] Result.", result)
+			result = append(result, v)
+		}
+		if decodeErr == nil {
+			metric.event(EventHit)
+			return result, true, nil
 		}
-		return result, true, nil
+		// 反序列化失败按miss处理，回填时整个list会被覆盖
+		metric.event(EventDecodeError)
+		logrus.Warn("[REDIS][LIST] key ", key, " decode error, reload from db! ", decodeErr)
 	} else {
 		if err != nil {
 			return nil, false, err
 		}
-		exists := GetRedisClient().Exists(options.Ctx, options.Key).Val()
+		exists := options.client().Exists(options.Ctx, key).Val()
 		if exists != 0 {
+			metric.event(EventHit)
 			return result, true, nil
 		}
+		metric.event(EventMiss)
 	}
 
 	logrus.Warn("[REDIS][LIST] cant get value from redis cache, maybe load from db!")
 	result, err = fallback()
 	if err != nil {
+		metric.event(EventFallbackError)
 		return nil, false, err
 	}
 	// 回填
-	rewriteCount := 0
-	if result != nil && len(result) > 0 {
-		var cacheVList []string
-		for _, item := range result {
-			cacheV, isEmpty, err := GetCacheValueItem(item)
-			if err != nil {
-				logrus.Warn("[REDIS][LIST] GetCacheValueItem error!", err)
-				continue
-			}
-			if !isEmpty {
-				rewriteCount++
-				cacheVList = append(cacheVList, cacheV)
-			}
+	var cacheVList []interface{}
+	for _, item := range result {
+		cacheV, isEmpty, err := GetCacheValueItem(item)
+		if err != nil {
+			logrus.Warn("[REDIS][LIST] GetCacheValueItem error!", err)
+			continue
 		}
-		UseGLock(options.Ctx, fmt.Sprintf("%s:lock", options.Key)).Then(func() (interface{}, error) {
-			GetRedisClient().RPush(options.Ctx, options.Key, cacheVList)
-			return nil, nil
-		})
-		if rewriteCount > 0 {
-			if options.Expires == 0 {
-				options.Expires = defaultExpire
-			}
-			GetRedisClient().Expire(options.Ctx, options.Key, options.Expires)
+		if !isEmpty {
+			cacheVList = append(cacheVList, cacheV)
 		}
 	}
-
-	// 空值回填
-	if rewriteCount == 0 && options.EmptyExpires > 0 {
-		GetRedisClient().RPush(options.Ctx, options.Key, EmptyFlag)
-		GetRedisClient().Expire(options.Ctx, options.Key, options.EmptyExpires)
-		logrus.Warn("[REDIS][LIST] cache empty value, key:", options.Key)
+	if len(cacheVList) > 0 {
+		if options.Expires == 0 {
+			options.Expires = defaultExpire
+		}
+		err = rewriteCollection(options.Ctx, options.client(), key, options.Expires, func(pipe redis.Pipeliner) {
+			pipe.RPush(options.Ctx, key, cacheVList...)
+		})
+		if err != nil {
+			metric.event(EventFillError)
+			logrus.Warn("[REDIS][LIST] rewrite cache error! ", err)
+		}
+	} else if options.EmptyExpires > 0 {
+		// 空值回填
+		err = rewriteCollection(options.Ctx, options.client(), key, options.EmptyExpires, func(pipe redis.Pipeliner) {
+			pipe.RPush(options.Ctx, key, EmptyFlag)
+		})
+		if err != nil {
+			metric.event(EventFillError)
+			logrus.Warn("[REDIS][LIST] rewrite cache error! ", err)
+		}
+		logrus.Warn("[REDIS][LIST] cache empty value, key:", key)
 	}
 
 	return result, false, nil
@@ -110,6 +126,27 @@ func (p *ListAopProxy) WithEmptyExpires(emptyExpires time.Duration) *ListAopProx
 	return p
 }
 
+func (p *ListAopProxy) WithClient(cli redis.UniversalClient) *ListAopProxy {
+	p.options.Client = cli
+	return p
+}
+
+func (p *ListAopProxy) WithBloom(bloom *BloomFilter) *ListAopProxy {
+	p.options.Bloom = bloom
+	return p
+}
+
+func (p *ListAopProxy) WithHotKey(detector *HotKeyDetector) *ListAopProxy {
+	p.options.HotKey = detector
+	return p
+}
+
+func (p *ListAopProxy) WithNamespace(namespace string, version int) *ListAopProxy {
+	p.options.Namespace = namespace
+	p.options.Version = version
+	return p
+}
+
 func (p *ListAopProxy) WithStart(start int64) *ListAopProxy {
 	p.options.Start = start
 	return p
@@ -136,3 +136,35 @@ func TestListAop(t *testing.T) {
 	}
 
 }
+
+// 多个实例同时回源时，集合里不能出现重复数据，并且一定要有过期时间
+func TestListAop_ConcurrentRewrite(t *testing.T) {
+	RedisTestSetup()
+	cacheKey := "testtest_list_" + strconv.Itoa(getRand())
+
+	wg := sync.WaitGroup{}
+	wg.Add(20)
+	for i := 0; i < 20; i++ {
+		go func(i int) {
+			defer wg.Done()
+			_, _, err := UseListAop(context.Background(), cacheKey, reflect.TypeOf(User{})).WithStart(0).WithStop(-1).WithExpires(time.Minute).Then(func() ([]interface{}, error) {
+				// 所有goroutine都已经错过cache，错开回填的时间
+				time.Sleep(time.Duration(i*10) * time.Millisecond)
+				return []interface{}{User{Id: 1}, User{Id: 2}}, nil
+			})
+			if err != nil {
+				t.Error("concurrent rewrite error", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	length, _ := GetRedisClient().LLen(context.Background(), cacheKey).Result()
+	if length != 2 {
+		t.Fatal("list must not be duplicated, length:", length)
+	}
+	ttl, _ := GetRedisClient().TTL(context.Background(), cacheKey).Result()
+	if ttl <= 0 {
+		t.Fatal("list must have ttl, ttl:", ttl)
+	}
+}
@@ -0,0 +1,111 @@
+package g_rediscache
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"math/rand"
+	"reflect"
+	"time"
+)
+
+type memoizeOptions struct {
+	expires      time.Duration
+	emptyExpires time.Duration
+	jitter       time.Duration
+	client       redis.UniversalClient
+}
+
+type MemoizeOption func(o *memoizeOptions)
+
+// MemoizeExpires 缓存时间，默认30s
+func MemoizeExpires(expires time.Duration) MemoizeOption {
+	return func(o *memoizeOptions) {
+		o.expires = expires
+	}
+}
+
+// MemoizeEmptyExpires 空值的缓存时间，为0时不缓存空值
+func MemoizeEmptyExpires(emptyExpires time.Duration) MemoizeOption {
+	return func(o *memoizeOptions) {
+		o.emptyExpires = emptyExpires
+	}
+}
+
+// MemoizeJitter 在缓存时间上随机增加[0, jitter)，避免同一批key同时过期
+func MemoizeJitter(jitter time.Duration) MemoizeOption {
+	return func(o *memoizeOptions) {
+		o.jitter = jitter
+	}
+}
+
+func MemoizeClient(cli redis.UniversalClient) MemoizeOption {
+	return func(o *memoizeOptions) {
+		o.client = cli
+	}
+}
+
+// KeyTemplate 用 fmt.Sprintf(tpl, arg) 生成缓存key
+func KeyTemplate[A any](tpl string) func(A) string {
+	return func(a A) string {
+		return fmt.Sprintf(tpl, a)
+	}
+}
+
+// Memoize 把一个DAO方法包装成带缓存的方法，底层使用SimpleAop
+//
+//	var GetUser = Memoize(KeyTemplate[int64]("user:%d"), dao.GetUser,
+//		MemoizeExpires(time.Minute), MemoizeEmptyExpires(10*time.Second))
+//
+// f返回nil指针、nil map等空值时视为不存在，设置了MemoizeEmptyExpires时会缓存空值，
+// 命中空值时返回R的零值
+func Memoize[A any, R any](keyFunc func(A) string, f func(context.Context, A) (R, error), opts ...MemoizeOption) func(context.Context, A) (R, error) {
+	o := &memoizeOptions{expires: defaultExpire}
+	for _, opt := range opts {
+		opt(o)
+	}
+	rt := reflect.TypeOf((*R)(nil)).Elem()
+
+	return func(ctx context.Context, a A) (R, error) {
+		var zero R
+		expires := o.expires
+		if o.jitter > 0 {
+			expires += time.Duration(rand.Int63n(int64(o.jitter)))
+		}
+		options := &SimpleOptions{Options{
+			Ctx:          ctx,
+			Key:          keyFunc(a),
+			Rt:           rt,
+			Expires:      expires,
+			EmptyExpires: o.emptyExpires,
+			Client:       o.client,
+		}}
+		v, _, err := SimpleAop(options, func() (interface{}, error) {
+			r, err := f(ctx, a)
+			if err != nil || isNilValue(r) {
+				return nil, err
+			}
+			return r, nil
+		})
+		if err != nil || v == nil {
+			return zero, err
+		}
+		r, ok := v.(R)
+		if !ok {
+			return zero, fmt.Errorf("memoize: cached value of key %s is %T, not %s", options.Key, v, rt)
+		}
+		return r, nil
+	}
+}
+
+func isNilValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Chan, reflect.Func:
+		return rv.IsNil()
+	}
+	return false
+}
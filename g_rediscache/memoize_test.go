@@ -0,0 +1,63 @@
+package g_rediscache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func ExampleMemoize() {
+	RedisTestSetup()
+	getUser := func(ctx context.Context, id int64) (*User, error) {
+		return &User{Id: id, Name: "name" + strconv.FormatInt(id, 10)}, nil
+	}
+	cachedGetUser := Memoize(KeyTemplate[int64]("testtest_memoize_"+strconv.Itoa(getRand())+":%d"), getUser,
+		MemoizeExpires(5*time.Second), MemoizeJitter(time.Second))
+
+	u, _ := cachedGetUser(context.Background(), 1)
+	fmt.Println(u.Name)
+	// output:
+	// name1
+}
+
+func TestMemoize(t *testing.T) {
+	RedisTestSetup()
+	calls := 0
+	getUser := func(ctx context.Context, id int64) (*User, error) {
+		calls++
+		if id == 0 {
+			return nil, nil
+		}
+		return &User{Id: id, Name: "name"}, nil
+	}
+	keyTpl := "testtest_memoize_" + strconv.Itoa(getRand()) + ":%d"
+	cachedGetUser := Memoize(KeyTemplate[int64](keyTpl), getUser,
+		MemoizeExpires(30*time.Second), MemoizeEmptyExpires(10*time.Second), MemoizeJitter(5*time.Second))
+
+	for i := 0; i < 3; i++ {
+		u, err := cachedGetUser(context.Background(), 1)
+		if err != nil || u == nil || u.Id != 1 {
+			t.Fatal("1. memoize FAIL", err, u)
+		}
+	}
+	if calls != 1 {
+		t.Fatal("1. f must be called once", calls)
+	}
+	ttl, _ := GetRedisClient().TTL(context.Background(), fmt.Sprintf(keyTpl, 1)).Result()
+	if ttl <= 0 || ttl > 35*time.Second {
+		t.Fatal("1. ttl with jitter not expected", ttl)
+	}
+
+	// 空值缓存
+	for i := 0; i < 3; i++ {
+		u, err := cachedGetUser(context.Background(), 0)
+		if err != nil || u != nil {
+			t.Fatal("2. memoize empty FAIL", err, u)
+		}
+	}
+	if calls != 2 {
+		t.Fatal("2. f must be called once for empty value", calls)
+	}
+}
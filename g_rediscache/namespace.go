@@ -0,0 +1,47 @@
+package g_rediscache
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	namespaceLock   sync.RWMutex
+	globalNamespace string
+	globalVersion   int
+)
+
+// SetNamespace 设置全局的key前缀和版本，AOP实际读写的key变成 namespace:v{version}:key
+// 缓存的结构体改变时升级version，旧版本的数据不会再被读到，等它们自然过期即可
+// 通过WithNamespace单独指定的proxy不受影响
+func SetNamespace(namespace string, version int) {
+	namespaceLock.Lock()
+	defer namespaceLock.Unlock()
+	globalNamespace = namespace
+	globalVersion = version
+}
+
+func getNamespace() (string, int) {
+	namespaceLock.RLock()
+	defer namespaceLock.RUnlock()
+	return globalNamespace, globalVersion
+}
+
+// namespace和version都为空时使用全局设置
+func namespacedKey(namespace string, version int, key string) string {
+	if namespace == "" && version == 0 {
+		namespace, version = getNamespace()
+	}
+	if namespace == "" && version == 0 {
+		return key
+	}
+	if namespace == "" {
+		return fmt.Sprintf("v%d:%s", version, key)
+	}
+	return fmt.Sprintf("%s:v%d:%s", namespace, version, key)
+}
+
+// 实际读写redis的key，埋点和布隆过滤器仍然使用原始的Key
+func (o *Options) cacheKey() string {
+	return namespacedKey(o.Namespace, o.Version, o.Key)
+}
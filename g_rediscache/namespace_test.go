@@ -0,0 +1,81 @@
+package g_rediscache
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestNamespace(t *testing.T) {
+	RedisTestSetup()
+	key := "testtest_namespace_" + strconv.Itoa(getRand())
+	calls := 0
+	load := func(name string) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			calls++
+			return User{Id: 1, Name: name}, nil
+		}
+	}
+
+	v, _, err := UseSimpleAop(context.Background(), key, reflect.TypeOf(User{})).WithNamespace("app", 1).Then(load("v1"))
+	if err != nil || v.(User).Name != "v1" {
+		t.Fatal("1. namespace FAIL", err, v)
+	}
+	if GetRedisClient().Exists(context.Background(), "app:v1:"+key).Val() != 1 {
+		t.Fatal("1. namespaced key not exists")
+	}
+	v, hit, _ := UseSimpleAop(context.Background(), key, reflect.TypeOf(User{})).WithNamespace("app", 1).Then(load("v1"))
+	if !hit || calls != 1 {
+		t.Fatal("2. same version must hit", hit, calls)
+	}
+
+	// 升级version后旧数据读不到
+	v, hit, err = UseSimpleAop(context.Background(), key, reflect.TypeOf(User{})).WithNamespace("app", 2).Then(load("v2"))
+	if err != nil || hit || v.(User).Name != "v2" || calls != 2 {
+		t.Fatal("3. version bump must reload", err, hit, v, calls)
+	}
+
+	// 全局namespace
+	SetNamespace("app", 2)
+	defer SetNamespace("", 0)
+	v, hit, _ = UseSimpleAop(context.Background(), key, reflect.TypeOf(User{})).Then(load("v2"))
+	if !hit || v.(User).Name != "v2" || calls != 2 {
+		t.Fatal("4. global namespace FAIL", hit, v, calls)
+	}
+	_, err = UseWriteThrough(context.Background(), key).Then(func() (interface{}, error) {
+		return nil, nil
+	})
+	if err != nil || GetRedisClient().Exists(context.Background(), "app:v2:"+key).Val() != 0 {
+		t.Fatal("5. write through must delete namespaced key", err)
+	}
+}
+
+func TestDecodeErrorReload(t *testing.T) {
+	RedisTestSetup()
+	ctx := context.Background()
+	key := "testtest_decode_" + strconv.Itoa(getRand())
+	GetRedisClient().Set(ctx, key, `{"Id":"not a number"}`, 0)
+
+	v, hit, err := UseSimpleAop(ctx, key, reflect.TypeOf(User{})).Then(func() (interface{}, error) {
+		return User{Id: 1, Name: "name"}, nil
+	})
+	if err != nil || hit || v.(User).Id != 1 {
+		t.Fatal("1. decode error must reload", err, hit, v)
+	}
+	v, hit, err = UseSimpleAop(ctx, key, reflect.TypeOf(User{})).Then(func() (interface{}, error) {
+		return nil, nil
+	})
+	if err != nil || !hit || v.(User).Id != 1 {
+		t.Fatal("2. reloaded value must overwrite cache", err, hit, v)
+	}
+
+	listKey := key + "_list"
+	GetRedisClient().RPush(ctx, listKey, `broken`, `{"Id":1}`)
+	vs, hit, err := UseListAop(ctx, listKey, reflect.TypeOf(User{})).Then(func() ([]interface{}, error) {
+		return []interface{}{User{Id: 2, Name: "name"}}, nil
+	})
+	if err != nil || hit || len(vs) != 1 || GetRedisClient().LLen(ctx, listKey).Val() != 1 {
+		t.Fatal("3. list decode error must reload", err, hit, vs)
+	}
+}
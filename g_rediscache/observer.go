@@ -0,0 +1,105 @@
+package g_rediscache
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// 埋点事件类型
+type CacheEvent string
+
+const (
+	EventHit           CacheEvent = "hit"
+	EventMiss          CacheEvent = "miss"
+	EventEmptyHit      CacheEvent = "empty_hit"
+	EventFallbackError CacheEvent = "fallback_error"
+	EventFillError     CacheEvent = "fill_error"
+	EventBloomReject   CacheEvent = "bloom_reject"
+	EventLocalHit      CacheEvent = "local_hit"
+	// cache里的值无法按Rt反序列化，一般是结构体改了但没有升级version，按miss处理并覆盖
+	EventDecodeError CacheEvent = "decode_error"
+)
+
+// 各个AOP在埋点里的名字
+const (
+	aopSimple = "simple"
+	aopHash   = "hash"
+	aopList   = "list"
+	aopSet    = "set"
+	aopZSet   = "zset"
+	aopBatch  = "batch"
+)
+
+// Observer 接收所有AOP的埋点，aop是AOP的名字（simple, hash, list, set, zset, batch），
+// prefix是通过KeyPrefixExtractor从key里提取的前缀，用来控制label的数量
+// 实现需要是并发安全的
+type Observer interface {
+	OnEvent(aop string, prefix string, event CacheEvent)
+	OnLatency(aop string, prefix string, d time.Duration)
+}
+
+// KeyPrefixExtractor 从缓存key中提取用于埋点的前缀
+type KeyPrefixExtractor func(key string) string
+
+var (
+	observerLock       sync.RWMutex
+	observer           Observer
+	keyPrefixExtractor KeyPrefixExtractor = DefaultKeyPrefix
+)
+
+// SetObserver 设置全局的埋点接收者，传nil关闭埋点
+func SetObserver(o Observer) {
+	observerLock.Lock()
+	defer observerLock.Unlock()
+	observer = o
+}
+
+// SetKeyPrefixExtractor 设置全局的key前缀提取方法，传nil恢复默认
+func SetKeyPrefixExtractor(f KeyPrefixExtractor) {
+	observerLock.Lock()
+	defer observerLock.Unlock()
+	if f == nil {
+		f = DefaultKeyPrefix
+	}
+	keyPrefixExtractor = f
+}
+
+// DefaultKeyPrefix 取第一个':'之前的部分，例如 "user:1001" 得到 "user"
+// key里没有':'时返回整个key，这种情况下建议自定义KeyPrefixExtractor，避免label过多
+func DefaultKeyPrefix(key string) string {
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// 一次AOP调用的埋点
+type aopMetric struct {
+	observer Observer
+	aop      string
+	prefix   string
+	start    time.Time
+}
+
+func startMetric(aop string, key string) *aopMetric {
+	observerLock.RLock()
+	o, extractor := observer, keyPrefixExtractor
+	observerLock.RUnlock()
+	if o == nil {
+		return &aopMetric{}
+	}
+	return &aopMetric{observer: o, aop: aop, prefix: extractor(key), start: time.Now()}
+}
+
+func (m *aopMetric) event(e CacheEvent) {
+	if m.observer != nil {
+		m.observer.OnEvent(m.aop, m.prefix, e)
+	}
+}
+
+func (m *aopMetric) done() {
+	if m.observer != nil {
+		m.observer.OnLatency(m.aop, m.prefix, time.Since(m.start))
+	}
+}
@@ -0,0 +1,77 @@
+package g_rediscache
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordObserver struct {
+	sync.Mutex
+	events    map[string]int
+	latencies int
+}
+
+func (o *recordObserver) OnEvent(aop string, prefix string, event CacheEvent) {
+	o.Lock()
+	defer o.Unlock()
+	o.events[aop+"|"+prefix+"|"+string(event)]++
+}
+
+func (o *recordObserver) OnLatency(aop string, prefix string, d time.Duration) {
+	o.Lock()
+	defer o.Unlock()
+	o.latencies++
+}
+
+func TestObserver(t *testing.T) {
+	RedisTestSetup()
+	o := &recordObserver{events: make(map[string]int)}
+	SetObserver(o)
+	defer SetObserver(nil)
+
+	cacheKey := "testtest_observer:" + strconv.Itoa(getRand())
+	proxy := func() *SimpleAopProxy {
+		return UseSimpleAop(context.Background(), cacheKey, reflect.TypeOf("")).WithEmptyExpires(10 * time.Second)
+	}
+	_, _, _ = proxy().Then(func() (interface{}, error) {
+		return nil, errors.New("db down")
+	})
+	_, _, _ = proxy().Then(func() (interface{}, error) {
+		return nil, nil
+	})
+	_, _, _ = proxy().Then(func() (interface{}, error) {
+		return nil, nil
+	})
+
+	expected := map[string]int{
+		"simple|testtest_observer|miss":           2,
+		"simple|testtest_observer|fallback_error": 1,
+		"simple|testtest_observer|empty_hit":      1,
+	}
+	if !reflect.DeepEqual(o.events, expected) {
+		t.Fatal("events not expected", o.events)
+	}
+	if o.latencies != 3 {
+		t.Fatal("latencies not expected", o.latencies)
+	}
+}
+
+func TestPrometheusObserver(t *testing.T) {
+	o := NewPrometheusObserver("test")
+	o.OnEvent(aopHash, "user", EventHit)
+	o.OnEvent(aopHash, "user", EventHit)
+	o.OnLatency(aopHash, "user", time.Millisecond)
+
+	if v := testutil.ToFloat64(o.events.WithLabelValues(aopHash, "user", string(EventHit))); v != 2 {
+		t.Fatal("hit counter not expected", v)
+	}
+	if n := testutil.CollectAndCount(o); n != 2 {
+		t.Fatal("collected metrics not expected", n)
+	}
+}
@@ -0,0 +1,52 @@
+package g_rediscache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+// PrometheusObserver 把AOP埋点转换成prometheus指标，本身也是一个prometheus.Collector
+//
+//	o := NewPrometheusObserver("myapp")
+//	prometheus.MustRegister(o)
+//	SetObserver(o)
+type PrometheusObserver struct {
+	events  *prometheus.CounterVec
+	latency *prometheus.HistogramVec
+}
+
+func NewPrometheusObserver(namespace string) *PrometheusObserver {
+	return &PrometheusObserver{
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rediscache",
+			Name:      "events_total",
+			Help:      "Number of redis cache events by aop, key prefix and event.",
+		}, []string{"aop", "prefix", "event"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "rediscache",
+			Name:      "latency_seconds",
+			Help:      "Latency of redis cache aop calls, including fallback and rewrite.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"aop", "prefix"}),
+	}
+}
+
+func (o *PrometheusObserver) OnEvent(aop string, prefix string, event CacheEvent) {
+	o.events.WithLabelValues(aop, prefix, string(event)).Inc()
+}
+
+func (o *PrometheusObserver) OnLatency(aop string, prefix string, d time.Duration) {
+	o.latency.WithLabelValues(aop, prefix).Observe(d.Seconds())
+}
+
+func (o *PrometheusObserver) Describe(ch chan<- *prometheus.Desc) {
+	o.events.Describe(ch)
+	o.latency.Describe(ch)
+}
+
+func (o *PrometheusObserver) Collect(ch chan<- prometheus.Metric) {
+	o.events.Collect(ch)
+	o.latency.Collect(ch)
+}
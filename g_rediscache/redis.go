@@ -1,15 +1,48 @@
 package g_rediscache
 
 import (
+	"errors"
 	"github.com/go-redis/redis/v8"
+	"sync"
 )
 
-var redisClient *redis.Client
+// ErrClientNotInitialized 没有调用InitRedisClient，也没有通过WithClient指定client
+var ErrClientNotInitialized = errors.New("redis client not initialized, call InitRedisClient or use WithClient")
 
-func InitRedisClient(cli *redis.Client) {
+var (
+	clientLock   sync.RWMutex
+	redisClient  redis.UniversalClient
+	namedClients = make(map[string]redis.UniversalClient)
+)
+
+// InitRedisClient 设置默认client，没有通过WithClient指定client的AOP都使用它
+// 单机、哨兵(redis.NewFailoverClient)、集群(redis.NewClusterClient)
+// 以及redis.NewUniversalClient创建的client都可以
+func InitRedisClient(cli redis.UniversalClient) {
+	clientLock.Lock()
+	defer clientLock.Unlock()
 	redisClient = cli
 }
 
-func GetRedisClient() *redis.Client {
+// GetRedisClient 返回默认client，没有初始化时返回nil
+func GetRedisClient() redis.UniversalClient {
+	clientLock.RLock()
+	defer clientLock.RUnlock()
 	return redisClient
 }
+
+// RegisterRedisClient 按名字注册client，例如把session和商品数据放在不同的集群
+func RegisterRedisClient(name string, cli redis.UniversalClient) {
+	clientLock.Lock()
+	defer clientLock.Unlock()
+	namedClients[name] = cli
+}
+
+// GetNamedRedisClient 按名字获取client，没有注册时返回nil
+//
+//	UseSimpleAop(ctx, key, rt).WithClient(GetNamedRedisClient("session"))
+func GetNamedRedisClient(name string) redis.UniversalClient {
+	clientLock.RLock()
+	defer clientLock.RUnlock()
+	return namedClients[name]
+}
@@ -0,0 +1,66 @@
+package g_rediscache
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestClientNotInitialized(t *testing.T) {
+	RedisTestSetup()
+	defaultClient := GetRedisClient()
+	InitRedisClient(nil)
+	defer InitRedisClient(defaultClient)
+
+	cacheKey := "testtest_client_" + strconv.Itoa(getRand())
+	_, _, err := UseSimpleAop(context.Background(), cacheKey, reflect.TypeOf("")).Then(func() (interface{}, error) {
+		return "abc", nil
+	})
+	if err != ErrClientNotInitialized {
+		t.Fatal("must return ErrClientNotInitialized", err)
+	}
+	_, err = UseGLock(context.Background(), cacheKey).Then(func() (interface{}, error) {
+		return nil, nil
+	})
+	if err != ErrClientNotInitialized {
+		t.Fatal("lock must return ErrClientNotInitialized", err)
+	}
+
+	// 指定client后可以正常使用
+	v, _, err := UseSimpleAop(context.Background(), cacheKey, reflect.TypeOf("")).WithClient(defaultClient).Then(func() (interface{}, error) {
+		return "abc", nil
+	})
+	if err != nil || v != "abc" {
+		t.Fatal("WithClient FAIL", err)
+	}
+}
+
+func TestNamedRedisClient(t *testing.T) {
+	RedisTestSetup()
+	RegisterRedisClient("test_db1", redis.NewClient(&redis.Options{
+		Addr:        "127.0.0.1:6379",
+		DialTimeout: 8 * time.Second,
+		DB:          1,
+	}))
+	if GetNamedRedisClient("not_exists") != nil {
+		t.Fatal("unregistered client must be nil")
+	}
+
+	cacheKey := "testtest_client_" + strconv.Itoa(getRand())
+	_, _, err := UseSimpleAop(context.Background(), cacheKey, reflect.TypeOf("")).
+		WithClient(GetNamedRedisClient("test_db1")).WithExpires(10 * time.Second).Then(func() (interface{}, error) {
+		return "abc", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := GetRedisClient().Exists(context.Background(), cacheKey).Result(); n != 0 {
+		t.Fatal("must not be written to default client")
+	}
+	if n, _ := GetNamedRedisClient("test_db1").Exists(context.Background(), cacheKey).Result(); n != 1 {
+		t.Fatal("must be written to named client")
+	}
+}
@@ -2,7 +2,7 @@ package g_rediscache
 
 import (
 	"context"
-	"encoding/json"
+	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 	"reflect"
 	"time"
@@ -17,67 +17,85 @@ func SetAop(options *SetOptions, fallback func() ([]interface{}, error)) ([]inte
 	if err != nil {
 		return nil, false, err
 	}
-	cacheVs, err := GetRedisClient().SMembers(options.Ctx, options.Key).Result()
+	key := options.cacheKey()
+	metric := startMetric(aopSet, options.Key)
+	defer metric.done()
+	if !options.bloomAllow(options.Key) {
+		metric.event(EventBloomReject)
+		return nil, false, nil
+	}
+	options.touchHotKey(key)
+	cacheVs, err := options.client().SMembers(options.Ctx, key).Result()
 
 	var result []interface{}
 	// 从cache里取到值
 	if len(cacheVs) > 0 {
 		if len(cacheVs) == 1 && cacheVs[0] == EmptyFlag {
+			metric.event(EventEmptyHit)
 			return result, true, nil
 		}
-		rtv := reflect.New(options.Rt)
-		rv := rtv.Interface()
+		var decodeErr error
 		for _, cacheV := range cacheVs {
 			if cacheV == EmptyFlag {
 				continue
 			}
-			if options.Rt.Kind() == reflect.String {
-				result = append(result, cacheV)
-			} else {
-				err := json.Unmarshal([]byte(cacheV), rv)
-				if err != nil {
-					return nil, false, err
-				}
-				result = append(result, reflect.ValueOf(rv).Elem().Interface())
+			v, err := decodeCacheValue(cacheV, options.Rt)
+			if err != nil {
+				decodeErr = err
+				break
 			}
+			result = append(result, v)
 		}
-		return result, true, err
+		if decodeErr == nil {
+			metric.event(EventHit)
+			return result, true, err
+		}
+		// 反序列化失败按miss处理，回填时整个set会被覆盖
+		metric.event(EventDecodeError)
+		logrus.Warn("[REDIS][SET] key ", key, " decode error, reload from db! ", decodeErr)
+		result = nil
+	} else {
+		metric.event(EventMiss)
 	}
 	logrus.Warn("[REDIS][SET] cant get value from redis cache, maybe load from db!")
 	result, err = fallback()
 	if err != nil {
+		metric.event(EventFallbackError)
 		return nil, false, err
 	}
 	// 回填
-	rewriteCount := 0
-	if result != nil && len(result) > 0 {
-		var val []string
-		for _, item := range result {
-			cacheV, isEmpty, err := GetCacheValueItem(item)
-			if err != nil {
-				logrus.Warn("[REDIS][SET] GetCacheValueItem error!", err)
-				continue
-			}
-			if !isEmpty {
-				val = append(val, cacheV)
-				rewriteCount++
-			}
+	var val []interface{}
+	for _, item := range result {
+		cacheV, isEmpty, err := GetCacheValueItem(item)
+		if err != nil {
+			logrus.Warn("[REDIS][SET] GetCacheValueItem error!", err)
+			continue
 		}
-
-		if rewriteCount > 0 {
-			GetRedisClient().SAdd(options.Ctx, options.Key, val)
-			if options.Expires == 0 {
-				options.Expires = defaultExpire
-			}
-			GetRedisClient().Expire(options.Ctx, options.Key, options.Expires)
+		if !isEmpty {
+			val = append(val, cacheV)
 		}
 	}
-
-	// 空值回填
-	if rewriteCount == 0 && options.EmptyExpires > 0 {
-		GetRedisClient().SAdd(options.Ctx, options.Key, EmptyFlag)
-		GetRedisClient().Expire(options.Ctx, options.Key, options.EmptyExpires)
-		logrus.Warn("[REDIS][SET] cache empty value, key:", options.Key)
+	if len(val) > 0 {
+		if options.Expires == 0 {
+			options.Expires = defaultExpire
+		}
+		err = rewriteCollection(options.Ctx, options.client(), key, options.Expires, func(pipe redis.Pipeliner) {
+			pipe.SAdd(options.Ctx, key, val...)
+		})
+		if err != nil {
+			metric.event(EventFillError)
+			logrus.Warn("[REDIS][SET] rewrite cache error! ", err)
+		}
+	} else if options.EmptyExpires > 0 {
+		// 空值回填
+		err = rewriteCollection(options.Ctx, options.client(), key, options.EmptyExpires, func(pipe redis.Pipeliner) {
+			pipe.SAdd(options.Ctx, key, EmptyFlag)
+		})
+		if err != nil {
+			metric.event(EventFillError)
+			logrus.Warn("[REDIS][SET] rewrite cache error! ", err)
+		}
+		logrus.Warn("[REDIS][SET] cache empty value, key:", key)
 	}
 
 	return result, false, nil
@@ -98,6 +116,27 @@ func (p *SetAopProxy) WithEmptyExpires(emptyExpires time.Duration) *SetAopProxy
 	return p
 }
 
+func (p *SetAopProxy) WithClient(cli redis.UniversalClient) *SetAopProxy {
+	p.options.Client = cli
+	return p
+}
+
+func (p *SetAopProxy) WithBloom(bloom *BloomFilter) *SetAopProxy {
+	p.options.Bloom = bloom
+	return p
+}
+
+func (p *SetAopProxy) WithHotKey(detector *HotKeyDetector) *SetAopProxy {
+	p.options.HotKey = detector
+	return p
+}
+
+func (p *SetAopProxy) WithNamespace(namespace string, version int) *SetAopProxy {
+	p.options.Namespace = namespace
+	p.options.Version = version
+	return p
+}
+
 func (p *SetAopProxy) Then(f func() ([]interface{}, error)) ([]interface{}, bool, error) {
 	return SetAop(&p.options, f)
 }
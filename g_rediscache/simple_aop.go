@@ -2,7 +2,7 @@ package g_rediscache
 
 import (
 	"context"
-	"encoding/json"
+	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 	"reflect"
 	"time"
@@ -17,23 +17,42 @@ func SimpleAop(options *SimpleOptions, fallback func() (interface{}, error)) (in
 	if err != nil {
 		return nil, false, err
 	}
-	cacheV, err := GetRedisClient().Get(options.Ctx, options.Key).Result()
+	key := options.cacheKey()
+	metric := startMetric(aopSimple, options.Key)
+	defer metric.done()
+	if !options.bloomAllow(options.Key) {
+		metric.event(EventBloomReject)
+		return nil, false, nil
+	}
+	local, localHit, promote := options.hotKeyGet(key)
+	if localHit {
+		metric.event(EventLocalHit)
+		return local, true, nil
+	}
+	cacheV, err := options.client().Get(options.Ctx, key).Result()
+	if cacheV == EmptyFlag {
+		metric.event(EventEmptyHit)
+		return nil, true, nil
+	}
 	if cacheV != "" {
-		rtv := reflect.New(options.Rt)
-		rv := rtv.Interface()
-		if cacheV == EmptyFlag {
-			return nil, true, nil
-		}
-		if options.Rt.Kind() == reflect.String {
-			return cacheV, true, nil
+		v, err := decodeCacheValue(cacheV, options.Rt)
+		if err == nil {
+			metric.event(EventHit)
+			if promote {
+				options.hotKeyPut(key, v)
+			}
+			return v, true, nil
 		}
-		err := json.Unmarshal([]byte(cacheV), rv)
-		return reflect.ValueOf(rv).Elem().Interface(), true, err
+		metric.event(EventDecodeError)
+		logrus.Warn("[REDIS][SIMPLE] key ", key, " decode error, reload from db! ", err)
+	} else {
+		metric.event(EventMiss)
 	}
 	logrus.Warn("[REDIS][SIMPLE] cant get value from redis cache, maybe load from db!")
 	var result interface{} = nil
 	result, err = fallback()
 	if err != nil {
+		metric.event(EventFallbackError)
 		return nil, false, err
 	}
 	// 是否回填cache成功
@@ -47,14 +66,22 @@ func SimpleAop(options *SimpleOptions, fallback func() (interface{}, error)) (in
 			if options.Expires == 0 {
 				options.Expires = defaultExpire
 			}
-			GetRedisClient().Set(options.Ctx, options.Key, cacheV, options.Expires).Val()
+			if err := options.client().Set(options.Ctx, key, cacheV, options.Expires).Err(); err != nil {
+				metric.event(EventFillError)
+				logrus.Warn("[REDIS][SIMPLE] rewrite cache error! ", err)
+			}
 			rewriteSuccess = true
 		}
 	}
 	// 是否需要存储空值
 	if !rewriteSuccess && options.EmptyExpires > 0 {
-		GetRedisClient().Set(options.Ctx, options.Key, EmptyFlag, options.EmptyExpires).Val()
-		logrus.Warn("[REDIS][SIMPLE] cache empty value, key:", options.Key)
+		if err := options.client().Set(options.Ctx, key, EmptyFlag, options.EmptyExpires).Err(); err != nil {
+			metric.event(EventFillError)
+		}
+		logrus.Warn("[REDIS][SIMPLE] cache empty value, key:", key)
+	}
+	if promote && rewriteSuccess {
+		options.hotKeyPut(key, result)
 	}
 	return result, false, nil
 }
@@ -73,6 +100,27 @@ func (p *SimpleAopProxy) WithEmptyExpires(emptyExpires time.Duration) *SimpleAop
 	return p
 }
 
+func (p *SimpleAopProxy) WithClient(cli redis.UniversalClient) *SimpleAopProxy {
+	p.options.Client = cli
+	return p
+}
+
+func (p *SimpleAopProxy) WithBloom(bloom *BloomFilter) *SimpleAopProxy {
+	p.options.Bloom = bloom
+	return p
+}
+
+func (p *SimpleAopProxy) WithHotKey(detector *HotKeyDetector) *SimpleAopProxy {
+	p.options.HotKey = detector
+	return p
+}
+
+func (p *SimpleAopProxy) WithNamespace(namespace string, version int) *SimpleAopProxy {
+	p.options.Namespace = namespace
+	p.options.Version = version
+	return p
+}
+
 func (p *SimpleAopProxy) Then(f func() (interface{}, error)) (interface{}, bool, error) {
 	return SimpleAop(&p.options, f)
 }
@@ -0,0 +1,245 @@
+package g_rediscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	gopool "github.com/zsyu9779/myUtil/pool"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFlushInterval = time.Second
+	defaultFlushBatch    = 500
+	defaultFlushRetry    = 3
+	defaultRetryBackoff  = 100 * time.Millisecond
+)
+
+// 从缓冲区取最多ARGV[1]个field移动到处理区，返回处理区里的所有数据
+// 处理区不为空说明上一批还没有写入db，先重试上一批，保证同一个field按写入顺序落库
+var takeBatchScript = redis.NewScript(`
+if redis.call('exists', KEYS[2]) == 0 then
+	local res = redis.call('hscan', KEYS[1], 0, 'COUNT', ARGV[1])
+	local kvs = res[2]
+	for i = 1, #kvs, 2 do
+		redis.call('hset', KEYS[2], kvs[i], kvs[i + 1])
+		redis.call('hdel', KEYS[1], kvs[i])
+	end
+end
+return redis.call('hgetall', KEYS[2])
+`)
+
+type WriteBehindOptions struct {
+	// 非空，缓冲区的名字，实际使用 {Key}:buffer 和 {Key}:processing 两个hash，集群模式下在同一个slot
+	Key string
+	// 定时flush的间隔，默认1s
+	Interval time.Duration
+	// 每批最多flush多少个field，默认500，redis的HSCAN COUNT只是建议值，实际可能略多
+	BatchSize int
+	// 一批写db失败后的重试次数和间隔，重试用完后这一批留在处理区，下次flush继续重试
+	MaxRetry     int
+	RetryBackoff time.Duration
+
+	// 为空时使用InitRedisClient设置的默认client
+	Client redis.UniversalClient
+	// 后台flush运行的pool，为空时使用gopool的默认pool
+	Pool gopool.Pool
+}
+
+// WriteBehind 写入先缓冲在redis的hash里，后台按批写入db
+// 同一个field多次Write只保留最后一次的值，Incr则会累加，flush收到的是累加后的增量
+type WriteBehind struct {
+	options WriteBehindOptions
+	flush   func(ctx context.Context, items map[string]string) error
+
+	bufferKey     string
+	processingKey string
+
+	lock    sync.Mutex
+	cancel  context.CancelFunc
+	stopped <-chan struct{}
+}
+
+// NewWriteBehind flush负责把一批数据写入db，返回错误时会重试
+func NewWriteBehind(options WriteBehindOptions, flush func(ctx context.Context, items map[string]string) error) (*WriteBehind, error) {
+	if options.Key == "" {
+		return nil, errors.New("Key must not be empty!")
+	}
+	if flush == nil {
+		return nil, errors.New("flush must not be nil!")
+	}
+	if options.Interval <= 0 {
+		options.Interval = defaultFlushInterval
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultFlushBatch
+	}
+	if options.MaxRetry <= 0 {
+		options.MaxRetry = defaultFlushRetry
+	}
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = defaultRetryBackoff
+	}
+	return &WriteBehind{
+		options:       options,
+		flush:         flush,
+		bufferKey:     fmt.Sprintf("{%s}:buffer", options.Key),
+		processingKey: fmt.Sprintf("{%s}:processing", options.Key),
+	}, nil
+}
+
+func (w *WriteBehind) client() (redis.UniversalClient, error) {
+	if w.options.Client != nil {
+		return w.options.Client, nil
+	}
+	if cli := GetRedisClient(); cli != nil {
+		return cli, nil
+	}
+	return nil, ErrClientNotInitialized
+}
+
+// Write 缓冲一次写入，覆盖同一个field之前未flush的值
+func (w *WriteBehind) Write(ctx context.Context, field string, value interface{}) error {
+	cli, err := w.client()
+	if err != nil {
+		return err
+	}
+	cacheV, _, err := GetCacheValueItem(value)
+	if err != nil {
+		return err
+	}
+	return cli.HSet(ctx, w.bufferKey, field, cacheV).Err()
+}
+
+// Incr 缓冲一次计数，适合点赞数、浏览数之类的计数器
+func (w *WriteBehind) Incr(ctx context.Context, field string, delta int64) error {
+	cli, err := w.client()
+	if err != nil {
+		return err
+	}
+	return cli.HIncrBy(ctx, w.bufferKey, field, delta).Err()
+}
+
+// Flush 同步flush一批数据，返回写入db的条数
+func (w *WriteBehind) Flush(ctx context.Context) (int, error) {
+	cli, err := w.client()
+	if err != nil {
+		return 0, err
+	}
+	kvs, err := takeBatchScript.Run(ctx, cli, []string{w.bufferKey, w.processingKey}, w.options.BatchSize).StringSlice()
+	if err != nil && err != redis.Nil {
+		return 0, err
+	}
+	if len(kvs) == 0 {
+		return 0, nil
+	}
+	items := make(map[string]string, len(kvs)/2)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		items[kvs[i]] = kvs[i+1]
+	}
+
+	for i := 0; i <= w.options.MaxRetry; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(w.options.RetryBackoff * time.Duration(i)):
+			}
+		}
+		err = w.flush(ctx, items)
+		if err == nil {
+			return len(items), cli.Del(ctx, w.processingKey).Err()
+		}
+		logrus.Warn("[REDIS][WRITE_BEHIND] flush ", w.options.Key, " error, retry: ", i, " ", err)
+	}
+	return 0, err
+}
+
+// Start 在pool上启动后台flush，重复调用无效
+// 不会阻塞，pool满了或者已经关闭时返回错误，可以稍后重试
+func (w *WriteBehind) Start() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.cancel != nil {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	// 用Background提交，loop自己监听ctx，否则Stop时还在排队的loop会被跳过，stopped永远不会关闭
+	var stopped <-chan struct{}
+	var err error
+	if w.options.Pool != nil {
+		// 任务执行完、被丢弃或被拒绝时Future都会完成
+		fu := gopool.TrySubmit(w.options.Pool, context.Background(), func(context.Context) (struct{}, error) {
+			w.loop(ctx)
+			return struct{}{}, nil
+		})
+		stopped = fu.Done()
+		select {
+		case <-fu.Done():
+			_, err = fu.Get(ctx)
+		default:
+		}
+	} else {
+		done := make(chan struct{})
+		stopped = done
+		err = gopool.TryGo(context.Background(), func() {
+			defer close(done)
+			w.loop(ctx)
+		})
+	}
+	if err != nil {
+		cancel()
+		logrus.Error("[REDIS][WRITE_BEHIND] start ", w.options.Key, " error! ", err)
+		return err
+	}
+	w.cancel, w.stopped = cancel, stopped
+	return nil
+}
+
+// Stop 停止后台flush，并把缓冲区里剩下的数据尽量写完
+func (w *WriteBehind) Stop(ctx context.Context) error {
+	w.lock.Lock()
+	cancel, stopped := w.cancel, w.stopped
+	w.cancel, w.stopped = nil, nil
+	w.lock.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for {
+		n, err := w.Flush(ctx)
+		if err != nil || n == 0 {
+			return err
+		}
+	}
+}
+
+func (w *WriteBehind) loop(ctx context.Context) {
+	ticker := time.NewTicker(w.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// 缓冲区积压时连续flush，直到取空
+			for {
+				n, err := w.Flush(ctx)
+				if err != nil {
+					logrus.Warn("[REDIS][WRITE_BEHIND] flush ", w.options.Key, " error! ", err)
+				}
+				if err != nil || n == 0 {
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package g_rediscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	gopool "github.com/zsyu9779/myUtil/pool"
+)
+
+func TestWriteBehind(t *testing.T) {
+	RedisTestSetup()
+	var lock sync.Mutex
+	db := make(map[string]string)
+	failTimes := 2
+	wb, err := NewWriteBehind(WriteBehindOptions{
+		Key:          "testtest_write_behind_" + strconv.Itoa(getRand()),
+		Interval:     100 * time.Millisecond,
+		BatchSize:    10,
+		RetryBackoff: 10 * time.Millisecond,
+	}, func(ctx context.Context, items map[string]string) error {
+		lock.Lock()
+		defer lock.Unlock()
+		// 前两次写db失败，触发重试
+		if failTimes > 0 {
+			failTimes--
+			return errors.New("db error")
+		}
+		for k, v := range items {
+			db[k] = v
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 25; i++ {
+		if err := wb.Write(ctx, "user:"+strconv.Itoa(i), User{Id: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = wb.Incr(ctx, "views", 3)
+	_ = wb.Incr(ctx, "views", 4)
+
+	if err := wb.Start(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if err := wb.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(db) != 26 || db["views"] != "7" || db["user:3"] != `{"Id":3,"Name":""}` {
+		t.Fatal("flush to db FAIL", len(db), db["views"], db["user:3"])
+	}
+}
+
+func TestWriteBehind_StopRejected(t *testing.T) {
+	RedisTestSetup()
+	config := gopool.NewConfig()
+	config.MaxQueueLength = 1
+	p := gopool.NewPool("testtest_write_behind", 1, config)
+	block := make(chan struct{})
+	defer close(block)
+	started := make(chan struct{})
+	p.Go(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	p.Go(func() {})
+
+	wb, err := NewWriteBehind(WriteBehindOptions{
+		Key:  "testtest_write_behind_" + strconv.Itoa(getRand()),
+		Pool: p,
+	}, func(ctx context.Context, items map[string]string) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pool满了，PolicyBlock下Start也不能阻塞，Stop不能一直等被拒绝的loop
+	done := make(chan error)
+	go func() {
+		if err := wb.Start(); err != gopool.ErrPoolFull {
+			done <- fmt.Errorf("Start must fail on a full pool: %v", err)
+			return
+		}
+		done <- wb.Stop(context.Background())
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start or Stop blocked on a full pool")
+	}
+}
@@ -0,0 +1,106 @@
+package g_rediscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// ErrCacheUpdate db已经更新成功，但是刷新或者删除cache失败
+var ErrCacheUpdate = errors.New("db updated but cache update failed")
+
+type WriteThroughOptions struct {
+	Ctx context.Context
+	// 非空
+	Key string
+	// true时用update返回的新值刷新cache，false时删除cache，由下一次读回填
+	Refresh bool
+	Expires time.Duration
+
+	// 为空时使用InitRedisClient设置的默认client
+	Client redis.UniversalClient
+	// 需要和读取时AOP使用的namespace一致，为空时使用SetNamespace设置的全局namespace
+	Namespace string
+	Version   int
+}
+
+func (o *WriteThroughOptions) client() redis.UniversalClient {
+	if o.Client != nil {
+		return o.Client
+	}
+	return GetRedisClient()
+}
+
+// WriteThrough 先通过update更新db，成功后刷新或者删除cache
+// update返回错误时不会动cache；cache操作失败时返回update的结果和包装了ErrCacheUpdate的错误
+func WriteThrough(options *WriteThroughOptions, update func() (interface{}, error)) (interface{}, error) {
+	if options.Key == "" {
+		return nil, errors.New("Key must not be empty!")
+	}
+	cli := options.client()
+	if cli == nil {
+		return nil, ErrClientNotInitialized
+	}
+	result, err := update()
+	if err != nil {
+		return nil, err
+	}
+	key := namespacedKey(options.Namespace, options.Version, options.Key)
+
+	if options.Refresh && result != nil {
+		cacheV, isEmpty, err := GetCacheValueItem(result)
+		if err != nil {
+			return result, err
+		}
+		if !isEmpty {
+			if options.Expires == 0 {
+				options.Expires = defaultExpire
+			}
+			err = cli.Set(options.Ctx, key, cacheV, options.Expires).Err()
+			if err != nil {
+				logrus.Warn("[REDIS][WRITE] refresh cache error, key:", key, " ", err)
+				return result, fmt.Errorf("%w: %v", ErrCacheUpdate, err)
+			}
+			return result, nil
+		}
+	}
+	err = cli.Del(options.Ctx, key).Err()
+	if err != nil {
+		logrus.Warn("[REDIS][WRITE] delete cache error, key:", key, " ", err)
+		return result, fmt.Errorf("%w: %v", ErrCacheUpdate, err)
+	}
+	return result, nil
+}
+
+type WriteThroughProxy struct {
+	options WriteThroughOptions
+}
+
+// WithRefresh 用新值刷新cache，而不是删除
+func (p *WriteThroughProxy) WithRefresh(expires time.Duration) *WriteThroughProxy {
+	p.options.Refresh = true
+	p.options.Expires = expires
+	return p
+}
+
+func (p *WriteThroughProxy) WithClient(cli redis.UniversalClient) *WriteThroughProxy {
+	p.options.Client = cli
+	return p
+}
+
+func (p *WriteThroughProxy) WithNamespace(namespace string, version int) *WriteThroughProxy {
+	p.options.Namespace = namespace
+	p.options.Version = version
+	return p
+}
+
+func (p *WriteThroughProxy) Then(f func() (interface{}, error)) (interface{}, error) {
+	return WriteThrough(&p.options, f)
+}
+
+func UseWriteThrough(ctx context.Context, key string) *WriteThroughProxy {
+	return &WriteThroughProxy{options: WriteThroughOptions{Ctx: ctx, Key: key}}
+}
@@ -0,0 +1,55 @@
+package g_rediscache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWriteThrough(t *testing.T) {
+	RedisTestSetup()
+	cacheKey := "testtest_write_" + strconv.Itoa(getRand())
+	read := func() (interface{}, bool, error) {
+		return UseSimpleAop(context.Background(), cacheKey, reflect.TypeOf(User{})).WithExpires(30 * time.Second).Then(func() (interface{}, error) {
+			return User{Id: 1, Name: "old"}, nil
+		})
+	}
+	_, _, _ = read()
+
+	// 刷新cache
+	_, err := UseWriteThrough(context.Background(), cacheKey).WithRefresh(30 * time.Second).Then(func() (interface{}, error) {
+		return User{Id: 1, Name: "new"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, fromCache, err := read()
+	if err != nil || !fromCache || v.(User).Name != "new" {
+		t.Fatal("1. refresh cache FAIL", err, v)
+	}
+
+	// update失败时不动cache
+	_, err = UseWriteThrough(context.Background(), cacheKey).Then(func() (interface{}, error) {
+		return nil, errors.New("db error")
+	})
+	if err == nil {
+		t.Fatal("2. update error must be returned")
+	}
+	if n, _ := GetRedisClient().Exists(context.Background(), cacheKey).Result(); n != 1 {
+		t.Fatal("2. cache must not be deleted")
+	}
+
+	// 删除cache
+	_, err = UseWriteThrough(context.Background(), cacheKey).Then(func() (interface{}, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := GetRedisClient().Exists(context.Background(), cacheKey).Result(); n != 0 {
+		t.Fatal("3. cache must be deleted")
+	}
+}
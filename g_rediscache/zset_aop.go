@@ -2,7 +2,7 @@ package g_rediscache
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 	"reflect"
@@ -25,9 +25,10 @@ type ZSetOptions struct {
 	// 如果是struct类型，需要指定一下，使用哪个字段作为score，否则score默认是0
 	ScoreField string
 
-	// 如果是ByScore，必须需要指定Min和Max
-	Min    int
-	Max    int
+	// 如果是ByScore，需要指定Min和Max，格式和ZRANGEBYSCORE一致，
+	// 例如 "1.5"，"(100" 表示不包含100，"-inf"，"+inf"，为空时分别是-inf和+inf
+	Min    string
+	Max    string
 	Offset int64
 	Count  int64
 
@@ -36,31 +37,82 @@ type ZSetOptions struct {
 	Stop  int64
 }
 
+const (
+	ScoreNegInf = "-inf"
+	ScorePosInf = "+inf"
+)
+
+// ScoreInclusive 包含score的边界
+func ScoreInclusive(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+// ScoreExclusive 不包含score的边界，例如 "(100"
+func ScoreExclusive(score float64) string {
+	return "(" + ScoreInclusive(score)
+}
+
 // NOTICE!!! 如果fallback返回结果的map的value一定是float64类型
 func ZSetAop(options *ZSetOptions, fallback func() (interface{}, error)) (interface{}, bool, error) {
+	err := options.validate()
+	if err != nil {
+		return nil, false, err
+	}
+	if options.Min == "" {
+		options.Min = ScoreNegInf
+	}
+	if options.Max == "" {
+		options.Max = ScorePosInf
+	}
 	zrangeBy := redis.ZRangeBy{
-		Min:    strconv.Itoa(options.Min),
-		Max:    strconv.Itoa(options.Max),
+		Min:    options.Min,
+		Max:    options.Max,
 		Offset: options.Offset,
 		Count:  options.Count,
 	}
 	if options.Stop == 0 {
 		options.Stop = -1
 	}
-	res, err := getFromCache(options, zrangeBy)
-	// 从缓存读取数据错误 直接返回
-	if err != nil {
-		return nil, false, err
+	key := options.cacheKey()
+	metric := startMetric(aopZSet, options.Key)
+	defer metric.done()
+	if !options.bloomAllow(options.Key) {
+		metric.event(EventBloomReject)
+		return nil, false, nil
 	}
-	// 返回值非空 且无报错
-	if res != nil && err == nil {
+	options.touchHotKey(key)
+	res, err := getFromCache(options, key, zrangeBy)
+	if errors.Is(err, errCacheDecode) {
+		// 反序列化失败按miss处理，回填时整个zset会被覆盖
+		metric.event(EventDecodeError)
+		logrus.Warn("[REDIS][ZSET] key ", key, " decode error, reload from db! ", err)
+	} else if err != nil {
+		// 从缓存读取数据错误 直接返回
+		return nil, false, err
+	} else if res != nil {
+		// 返回值非空 且无报错
+		if reflect.ValueOf(res).Len() == 0 {
+			metric.event(EventEmptyHit)
+		} else {
+			metric.event(EventHit)
+		}
 		return res, true, nil
+	} else if options.client().Exists(options.Ctx, key).Val() != 0 {
+		// key存在只是范围内没有成员，不能回填，否则会覆盖整个zset
+		metric.event(EventHit)
+		if options.IsMap {
+			return make(map[interface{}]float64), true, nil
+		}
+		return []interface{}(nil), true, nil
+	} else {
+		metric.event(EventMiss)
 	}
 	// 返回值为空 且无报错 则缓存中无数据（且不为空标记） 需要reload 执行fallback
 	logrus.Info("[REDIS][ZSET] cant get value from redis cache, maybe load from db!")
 
 	fResult, err := fallback()
 	if err != nil {
+		metric.event(EventFallbackError)
 		return nil, false, err
 	}
 	if fResult == nil {
@@ -109,42 +161,45 @@ func ZSetAop(options *ZSetOptions, fallback func() (interface{}, error)) (interf
 		}
 
 	}
-	if len(members) > 0 {
-		if err := GetRedisClient().ZAdd(options.Ctx, options.Key, members...).Err(); err != nil {
-			return nil, false, err
-		}
-	}
 	if rewriteCount > 0 {
-		if err := GetRedisClient().Expire(options.Ctx, options.Key, options.Expires).Err(); err != nil {
+		if options.Expires == 0 {
+			options.Expires = defaultExpire
+		}
+		err = rewriteCollection(options.Ctx, options.client(), key, options.Expires, func(pipe redis.Pipeliner) {
+			pipe.ZAdd(options.Ctx, key, members...)
+		})
+		if err != nil {
+			metric.event(EventFillError)
 			return nil, false, err
 		}
-	} else {
+	} else if options.EmptyExpires > 0 {
 		// 空值回填
-		if rewriteCount == 0 && options.EmptyExpires > 0 {
-			if err := GetRedisClient().ZAdd(options.Ctx, options.Key, &redis.Z{Member: EmptyFlag}).Err(); err != nil {
-				return nil, false, err
-			}
-			GetRedisClient().Expire(options.Ctx, options.Key, options.EmptyExpires).Val()
-			logrus.Warn("[REDIS][ZSET] cache empty value, key:", options.Key)
+		err = rewriteCollection(options.Ctx, options.client(), key, options.EmptyExpires, func(pipe redis.Pipeliner) {
+			pipe.ZAdd(options.Ctx, key, &redis.Z{Member: EmptyFlag})
+		})
+		if err != nil {
+			metric.event(EventFillError)
+			return nil, false, err
 		}
+		logrus.Warn("[REDIS][ZSET] cache empty value, key:", key)
 	}
 	// 回填完成 再次从缓存中取排序好的数据
-	res2, err := getFromCache(options, zrangeBy)
+	res2, err := getFromCache(options, key, zrangeBy)
 	return res2, false, err
 }
 
-func getFromCache(options *ZSetOptions, zrangeBy redis.ZRangeBy) (interface{}, error) {
+func getFromCache(options *ZSetOptions, key string, zrangeBy redis.ZRangeBy) (interface{}, error) {
 	var cacheVs []redis.Z
 	var err error = nil
-	client := GetRedisClient()
+	client := options.client()
 	if options.Desc && options.ByScore {
-		cacheVs, err = client.ZRevRangeByScoreWithScores(options.Ctx, options.Key, &zrangeBy).Result()
+		cacheVs, err = client.ZRevRangeByScoreWithScores(options.Ctx, key, &zrangeBy).Result()
 	} else if options.Desc {
-		cacheVs, err = client.ZRevRangeWithScores(options.Ctx, options.Key, options.Start, options.Stop).Result()
+		cacheVs, err = client.ZRevRangeWithScores(options.Ctx, key, options.Start, options.Stop).Result()
 	} else if options.ByScore {
-		cacheVs, err = client.ZRangeByScoreWithScores(options.Ctx, options.Key, &zrangeBy).Result()
+		cacheVs, err = client.ZRangeByScoreWithScores(options.Ctx, key, &zrangeBy).Result()
 	} else {
-		cacheVs, err = client.ZRangeWithScores(options.Ctx, options.Key, options.Start, options.Stop).Result()
+		cacheVs, err = client.ZRangeWithScores(options.Ctx, key, options.Start, options.Stop).Result()
 	}
 	if err != nil {
 		return nil, err
@@ -160,13 +215,10 @@ func getFromCache(options *ZSetOptions, zrangeBy redis.ZRangeBy) (interface{}, e
 			}
 		}
 		for _, cacheV := range cacheVs {
-			rtv := reflect.New(options.Rt)
-			rv := rtv.Interface()
-			err := json.Unmarshal([]byte(cacheV.Member.(string)), rv)
+			vv, err := decodeCacheValue(cacheV.Member.(string), options.Rt)
 			if err != nil {
 				return nil, err
 			}
-			vv := reflect.ValueOf(rv).Elem().Interface()
 
 			if options.IsMap {
 				mapResult[vv] = cacheV.Score
@@ -197,6 +249,27 @@ func (p *ZSetAopProxy) WithEmptyExpires(emptyExpires time.Duration) *ZSetAopProx
 	return p
 }
 
+func (p *ZSetAopProxy) WithClient(cli redis.UniversalClient) *ZSetAopProxy {
+	p.options.Client = cli
+	return p
+}
+
+func (p *ZSetAopProxy) WithBloom(bloom *BloomFilter) *ZSetAopProxy {
+	p.options.Bloom = bloom
+	return p
+}
+
+func (p *ZSetAopProxy) WithHotKey(detector *HotKeyDetector) *ZSetAopProxy {
+	p.options.HotKey = detector
+	return p
+}
+
+func (p *ZSetAopProxy) WithNamespace(namespace string, version int) *ZSetAopProxy {
+	p.options.Namespace = namespace
+	p.options.Version = version
+	return p
+}
+
 func (p *ZSetAopProxy) WithIsMap(isMap bool) *ZSetAopProxy {
 	p.options.IsMap = isMap
 	return p
@@ -217,12 +290,26 @@ func (p *ZSetAopProxy) WithScoreField(scoreField string) *ZSetAopProxy {
 	return p
 }
 
-func (p *ZSetAopProxy) WithMin(min int) *ZSetAopProxy {
+// WithMin 包含min
+func (p *ZSetAopProxy) WithMin(min float64) *ZSetAopProxy {
+	p.options.Min = ScoreInclusive(min)
+	return p
+}
+
+// WithMax 包含max
+func (p *ZSetAopProxy) WithMax(max float64) *ZSetAopProxy {
+	p.options.Max = ScoreInclusive(max)
+	return p
+}
+
+// WithMinBound 直接指定ZRANGEBYSCORE的min，例如 ScoreExclusive(100)，ScoreNegInf
+func (p *ZSetAopProxy) WithMinBound(min string) *ZSetAopProxy {
 	p.options.Min = min
 	return p
 }
 
-func (p *ZSetAopProxy) WithMax(max int) *ZSetAopProxy {
+// WithMaxBound 直接指定ZRANGEBYSCORE的max，例如 ScoreExclusive(100)，ScorePosInf
+func (p *ZSetAopProxy) WithMaxBound(max string) *ZSetAopProxy {
 	p.options.Max = max
 	return p
 }
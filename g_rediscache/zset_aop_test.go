@@ -106,4 +106,17 @@ func TestZSetAop(t *testing.T) {
 	if err != nil || !fromCache || len(valarr) != 2 || u1.Name != "name1" || u1.Id != 1 || u2.Name != "name2" || u2.Id != 2 {
 		t.Fatal("4. must not be from cache FAIL")
 	}
+
+	// 第五次, 按score查一个没有成员的范围，不能回填覆盖已有的zset
+	val, fromCache, err = UseZSetAop(context.Background(), cacheKey, reflect.TypeOf(User{})).
+		WithScoreField("Id").WithByScore(true).WithMin(100).WithMax(200).WithEmptyExpires(2 * time.Second).
+		Then(func() (interface{}, error) {
+			return []interface{}{}, nil
+		})
+	if err != nil || !fromCache || len(val.([]interface{})) != 0 {
+		t.Fatal("5. empty range must be from cache FAIL", err, fromCache, val)
+	}
+	if n := GetRedisClient().ZCard(context.Background(), cacheKey).Val(); n != 2 {
+		t.Fatal("5. zset must not be rewritten FAIL", n)
+	}
 }
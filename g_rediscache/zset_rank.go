@@ -0,0 +1,140 @@
+package g_rediscache
+
+import (
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+)
+
+// 只有排行榜已经在cache里时才更新，避免cache过期后只写入了一部分成员，被ZSetAop当成完整的排行榜返回
+// 更新时顺便去掉空值标记
+var zIncrByScript = redis.NewScript(`
+if redis.call('exists', KEYS[1]) == 0 then
+	return false
+end
+redis.call('zrem', KEYS[1], ARGV[3])
+return redis.call('zincrby', KEYS[1], ARGV[1], ARGV[2])
+`)
+
+var zAddScript = redis.NewScript(`
+if redis.call('exists', KEYS[1]) == 0 then
+	return false
+end
+redis.call('zrem', KEYS[1], ARGV[1])
+for i = 2, #ARGV, 2 do
+	redis.call('zadd', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+return (#ARGV - 1) / 2
+`)
+
+// 成员和回填时一样通过GetCacheValueItem序列化，保证是同一个member
+func zsetMember(member interface{}) (string, error) {
+	cacheV, isEmpty, err := GetCacheValueItem(member)
+	if err != nil {
+		return "", err
+	}
+	if isEmpty {
+		return "", errors.New("member must not be empty!")
+	}
+	return cacheV, nil
+}
+
+// IncrBy 给排行榜里的成员加分，返回新的分数
+// 排行榜不在cache里时不做任何操作，返回false，等下一次ZSetAop从db回填
+func (p *ZSetAopProxy) IncrBy(member interface{}, incr float64) (float64, bool, error) {
+	if err := p.options.validate(); err != nil {
+		return 0, false, err
+	}
+	m, err := zsetMember(member)
+	if err != nil {
+		return 0, false, err
+	}
+	score, err := zIncrByScript.Run(p.options.Ctx, p.options.client(), []string{p.options.cacheKey()},
+		ScoreInclusive(incr), m, EmptyFlag).Text()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	f, err := strconv.ParseFloat(score, 64)
+	return f, err == nil, err
+}
+
+// Add 添加或者更新排行榜里的成员，Member会被序列化
+// 排行榜不在cache里时不做任何操作，返回false
+func (p *ZSetAopProxy) Add(members ...*redis.Z) (bool, error) {
+	if err := p.options.validate(); err != nil {
+		return false, err
+	}
+	if len(members) == 0 {
+		return false, nil
+	}
+	args := []interface{}{EmptyFlag}
+	for _, z := range members {
+		m, err := zsetMember(z.Member)
+		if err != nil {
+			return false, err
+		}
+		args = append(args, ScoreInclusive(z.Score), m)
+	}
+	err := zAddScript.Run(p.options.Ctx, p.options.client(), []string{p.options.cacheKey()}, args...).Err()
+	if err == redis.Nil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Rem 从排行榜里删除成员，返回删除的个数
+func (p *ZSetAopProxy) Rem(members ...interface{}) (int64, error) {
+	if err := p.options.validate(); err != nil {
+		return 0, err
+	}
+	var ms []interface{}
+	for _, member := range members {
+		m, err := zsetMember(member)
+		if err != nil {
+			return 0, err
+		}
+		ms = append(ms, m)
+	}
+	return p.options.client().ZRem(p.options.Ctx, p.options.cacheKey(), ms...).Result()
+}
+
+// Rank 返回成员的排名，从0开始，WithDesc(true)时按分数从高到低排
+// 成员不存在或者排行榜不在cache里时返回false
+func (p *ZSetAopProxy) Rank(member interface{}) (int64, bool, error) {
+	if err := p.options.validate(); err != nil {
+		return 0, false, err
+	}
+	m, err := zsetMember(member)
+	if err != nil {
+		return 0, false, err
+	}
+	var rank int64
+	if p.options.Desc {
+		rank, err = p.options.client().ZRevRank(p.options.Ctx, p.options.cacheKey(), m).Result()
+	} else {
+		rank, err = p.options.client().ZRank(p.options.Ctx, p.options.cacheKey(), m).Result()
+	}
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	return rank, err == nil, err
+}
+
+// Score 返回成员的分数，成员不存在或者排行榜不在cache里时返回false
+func (p *ZSetAopProxy) Score(member interface{}) (float64, bool, error) {
+	if err := p.options.validate(); err != nil {
+		return 0, false, err
+	}
+	m, err := zsetMember(member)
+	if err != nil {
+		return 0, false, err
+	}
+	score, err := p.options.client().ZScore(p.options.Ctx, p.options.cacheKey(), m).Result()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	return score, err == nil, err
+}
@@ -0,0 +1,90 @@
+package g_rediscache
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestZSetAop_ScoreBound(t *testing.T) {
+	RedisTestSetup()
+	cacheKey := "testtest_zset_rank_" + strconv.Itoa(getRand())
+	bizFunc := func() (interface{}, error) {
+		return map[interface{}]float64{"a": 1.5, "b": 2.5, "c": 3.5}, nil
+	}
+	use := func() *ZSetAopProxy {
+		return UseZSetAop(context.Background(), cacheKey, reflect.TypeOf("")).WithIsMap(true).WithExpires(30 * time.Second)
+	}
+	_, _, err := use().Then(bizFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val, fromCache, err := use().WithByScore(true).WithMinBound(ScoreExclusive(1.5)).WithMax(3.5).Then(bizFunc)
+	valm := val.(map[interface{}]float64)
+	if err != nil || !fromCache || len(valm) != 2 || valm["b"] != 2.5 || valm["c"] != 3.5 {
+		t.Fatal("exclusive min FAIL", err, valm)
+	}
+	val, _, err = use().WithByScore(true).WithMinBound(ScoreNegInf).WithMaxBound(ScoreExclusive(2.5)).Then(bizFunc)
+	valm = val.(map[interface{}]float64)
+	if err != nil || len(valm) != 1 || valm["a"] != 1.5 {
+		t.Fatal("exclusive max FAIL", err, valm)
+	}
+}
+
+func TestZSetAopProxy_Rank(t *testing.T) {
+	RedisTestSetup()
+	cacheKey := "testtest_zset_rank_" + strconv.Itoa(getRand())
+	use := func() *ZSetAopProxy {
+		return UseZSetAop(context.Background(), cacheKey, reflect.TypeOf("")).WithIsMap(true).WithDesc(true).WithExpires(30 * time.Second)
+	}
+
+	// 排行榜不在cache里时不更新
+	score, ok, err := use().IncrBy("a", 1)
+	if err != nil || ok {
+		t.Fatal("1. must not incr missing ranking", err, score)
+	}
+	if ok, err := use().Add(&redis.Z{Member: "d", Score: 1}); err != nil || ok {
+		t.Fatal("1. must not add to missing ranking", err)
+	}
+	if n, _ := GetRedisClient().Exists(context.Background(), cacheKey).Result(); n != 0 {
+		t.Fatal("1. ranking must not be created")
+	}
+
+	_, _, err = use().Then(func() (interface{}, error) {
+		return map[interface{}]float64{"a": 1, "b": 2, "c": 3}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score, ok, err = use().IncrBy("a", 10.5)
+	if err != nil || !ok || score != 11.5 {
+		t.Fatal("2. incr FAIL", err, score)
+	}
+	if ok, err := use().Add(&redis.Z{Member: "d", Score: 2.5}); err != nil || !ok {
+		t.Fatal("2. add FAIL", err)
+	}
+	if n, err := use().Rem("b"); err != nil || n != 1 {
+		t.Fatal("2. rem FAIL", err, n)
+	}
+
+	rank, ok, err := use().Rank("a")
+	if err != nil || !ok || rank != 0 {
+		t.Fatal("3. rank FAIL", err, rank)
+	}
+	rank, ok, err = use().Rank("d")
+	if err != nil || !ok || rank != 2 {
+		t.Fatal("3. rank FAIL", err, rank)
+	}
+	if _, ok, err = use().Rank("b"); err != nil || ok {
+		t.Fatal("3. removed member must not have rank", err)
+	}
+	score, ok, err = use().Score("d")
+	if err != nil || !ok || score != 2.5 {
+		t.Fatal("4. score FAIL", err, score)
+	}
+}
@@ -0,0 +1,165 @@
+package gopool
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultAutoscaleInterval = time.Second
+	defaultTargetQueueWait   = 10 * time.Millisecond
+	defaultDecreaseFactor    = 0.5
+	// throughput dropping more than this after an increase means the downstream is saturated
+	throughputTolerance = 0.1
+)
+
+type AutoscaleOptions struct {
+	// the cap is kept in [MinCap, MaxCap]
+	MinCap int32
+	MaxCap int32
+	// how often the cap is adjusted, defaults to 1s
+	Interval time.Duration
+	// the pool is congested if the average queue wait in an interval exceeds TargetQueueWait, defaults to 10ms
+	TargetQueueWait time.Duration
+	// added to the cap when the pool is congested and throughput keeps up, defaults to 1
+	Increase int32
+	// multiplied to the cap when the pool is congested and throughput drops, defaults to 0.5
+	DecreaseFactor float64
+
+	// called after the cap is changed, should not block
+	OnScale func(e ScaleEvent)
+}
+
+// ScaleEvent describes a change of the cap made by Autoscaler.
+type ScaleEvent struct {
+	Pool   string
+	OldCap int32
+	NewCap int32
+	// average queue wait and completed tasks per second in the last interval
+	QueueWait  time.Duration
+	Throughput float64
+}
+
+// Autoscaler adjusts the cap of a pool by AIMD: the cap grows by Increase while tasks wait too long
+// in the queue and throughput keeps up, and shrinks by DecreaseFactor once more workers make throughput
+// drop, e.g. when the database behind the pool is overloaded.
+//
+//	a := gopool.Autoscale(p, gopool.AutoscaleOptions{MinCap: 4, MaxCap: 64})
+//	defer a.Stop()
+type Autoscaler struct {
+	pool    Pool
+	options AutoscaleOptions
+
+	last           Stats
+	lastThroughput float64
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// Autoscale starts an Autoscaler for p, the cap of p is clamped into [MinCap, MaxCap] at once.
+func Autoscale(p Pool, options AutoscaleOptions) *Autoscaler {
+	a := newAutoscaler(p, options)
+	go a.run()
+	return a
+}
+
+func newAutoscaler(p Pool, options AutoscaleOptions) *Autoscaler {
+	if options.MinCap <= 0 {
+		options.MinCap = 1
+	}
+	if options.MaxCap < options.MinCap {
+		options.MaxCap = options.MinCap
+	}
+	if options.Interval <= 0 {
+		options.Interval = defaultAutoscaleInterval
+	}
+	if options.TargetQueueWait <= 0 {
+		options.TargetQueueWait = defaultTargetQueueWait
+	}
+	if options.Increase <= 0 {
+		options.Increase = 1
+	}
+	if options.DecreaseFactor <= 0 || options.DecreaseFactor >= 1 {
+		options.DecreaseFactor = defaultDecreaseFactor
+	}
+	a := &Autoscaler{
+		pool:    p,
+		options: options,
+		last:    p.Stats(),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if c := a.clamp(a.last.Cap); c != a.last.Cap {
+		p.SetCap(c)
+		a.last.Cap = c
+	}
+	return a
+}
+
+func (a *Autoscaler) run() {
+	defer close(a.stopped)
+	ticker := time.NewTicker(a.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			a.adjust(a.pool.Stats(), a.options.Interval)
+		}
+	}
+}
+
+// Stop stops adjusting the cap, the cap stays as it is.
+func (a *Autoscaler) Stop() {
+	a.stopOnce.Do(func() {
+		close(a.stop)
+	})
+	<-a.stopped
+}
+
+func (a *Autoscaler) clamp(c int32) int32 {
+	if c < a.options.MinCap {
+		return a.options.MinCap
+	}
+	if c > a.options.MaxCap {
+		return a.options.MaxCap
+	}
+	return c
+}
+
+// adjust compares s with the stats of the last interval and changes the cap if needed.
+func (a *Autoscaler) adjust(s Stats, elapsed time.Duration) {
+	var wait time.Duration
+	if n := s.QueueWait.Count - a.last.QueueWait.Count; n > 0 {
+		wait = time.Duration((s.QueueWait.Sum - a.last.QueueWait.Sum) / float64(n) * float64(time.Second))
+	}
+	throughput := float64(s.Completed+s.Panicked-a.last.Completed-a.last.Panicked) / elapsed.Seconds()
+	lastThroughput := a.lastThroughput
+	a.last, a.lastThroughput = s, throughput
+
+	if wait <= a.options.TargetQueueWait {
+		return
+	}
+	newCap := s.Cap + a.options.Increase
+	if throughput < lastThroughput*(1-throughputTolerance) {
+		newCap = int32(float64(s.Cap) * a.options.DecreaseFactor)
+	}
+	newCap = a.clamp(newCap)
+	if newCap == s.Cap {
+		return
+	}
+	a.pool.SetCap(newCap)
+	a.last.Cap = newCap
+	if a.options.OnScale != nil {
+		a.options.OnScale(ScaleEvent{
+			Pool:       s.Name,
+			OldCap:     s.Cap,
+			NewCap:     newCap,
+			QueueWait:  wait,
+			Throughput: throughput,
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package gopool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoscaler(t *testing.T) {
+	p := NewPool("test", 100, NewConfig())
+	var events []ScaleEvent
+	a := newAutoscaler(p, AutoscaleOptions{
+		MinCap:          2,
+		MaxCap:          10,
+		TargetQueueWait: 10 * time.Millisecond,
+		Increase:        2,
+		OnScale: func(e ScaleEvent) {
+			events = append(events, e)
+		},
+	})
+	if p.Stats().Cap != 10 {
+		t.Fatal("cap must be clamped into [MinCap, MaxCap]", p.Stats().Cap)
+	}
+	p.SetCap(4)
+
+	stats := p.Stats()
+	next := func(completed uint64, wait time.Duration, count uint64) Stats {
+		stats.Cap = p.Stats().Cap
+		stats.Completed += completed
+		stats.QueueWait.Count += count
+		stats.QueueWait.Sum += wait.Seconds() * float64(count)
+		return stats
+	}
+
+	// 没有排队，不调整
+	a.adjust(next(100, time.Millisecond, 100), time.Second)
+	if p.Stats().Cap != 4 || len(events) != 0 {
+		t.Fatal("cap must not change without congestion", p.Stats().Cap)
+	}
+	// 排队且吞吐没有下降，加性增加
+	a.adjust(next(120, 50*time.Millisecond, 120), time.Second)
+	a.adjust(next(150, 50*time.Millisecond, 150), time.Second)
+	if p.Stats().Cap != 8 || len(events) != 2 {
+		t.Fatal("cap must increase additively", p.Stats().Cap)
+	}
+	// 吞吐下降，乘性减少
+	a.adjust(next(60, 100*time.Millisecond, 60), time.Second)
+	if p.Stats().Cap != 4 {
+		t.Fatal("cap must decrease multiplicatively", p.Stats().Cap)
+	}
+	e := events[len(events)-1]
+	if e.OldCap != 8 || e.NewCap != 4 || e.QueueWait != 100*time.Millisecond || e.Throughput != 60 {
+		t.Fatalf("scale event not expected: %+v", e)
+	}
+	// 不会超过MaxCap
+	for i := 0; i < 10; i++ {
+		a.adjust(next(100, 50*time.Millisecond, 100), time.Second)
+	}
+	if p.Stats().Cap != 10 {
+		t.Fatal("cap must not exceed MaxCap", p.Stats().Cap)
+	}
+
+	a = Autoscale(p, AutoscaleOptions{MinCap: 2, MaxCap: 10, Interval: 10 * time.Millisecond})
+	time.Sleep(30 * time.Millisecond)
+	a.Stop()
+}
@@ -1,9 +1,29 @@
 package gopool
 
+import (
+	"context"
+	"time"
+)
+
 const (
 	defaultScalaThreshold = 1
 )
 
+// RejectPolicy decides what to do with a new task when the queue is full.
+type RejectPolicy int
+
+const (
+	// PolicyBlock blocks the caller until the queue has room or ctx is done.
+	// The task is dropped if ctx is done first.
+	PolicyBlock RejectPolicy = iota
+	// PolicyReject drops the new task, TryGo returns ErrPoolFull.
+	PolicyReject
+	// PolicyDropOldest drops the oldest queued task to make room for the new one.
+	PolicyDropOldest
+	// PolicyCallerRuns runs the new task in the caller's goroutine, TryGo returns ErrPoolFull instead.
+	PolicyCallerRuns
+)
+
 // Config is used to config pool.
 type Config struct {
 	// threshold for scale.
@@ -11,6 +31,48 @@ type Config struct {
 	// defaults to defaultScalaThreshold.
 	//扩容规模阈值，若len(task chan) > ScaleThreshold，则新建goroutine
 	ScaleThreshold int32
+
+	// max number of queued tasks, 0 means unbounded.
+	// 等待执行的任务数上限，为0时不限制
+	MaxQueueLength int32
+	// what to do when the queue is full, defaults to PolicyBlock.
+	RejectPolicy RejectPolicy
+
+	// the pool-wide max queue wait, tasks not started within TaskTimeout after submission are skipped
+	// with ErrTaskTimeout, 0 means no limit. It doesn't bound the execution, use CtxGoTimeout for a per-task deadline.
+	// 任务在队列里等待超过TaskTimeout后不再执行
+	TaskTimeout time.Duration
+	// called when a task is skipped because its ctx is done or TaskTimeout is exceeded,
+	// or when a run of GoAfter, GoAt or GoEvery is rejected by the pool.
+	SkipHandler func(ctx context.Context, err error)
+	// called before a task runs with the time it waited in the queue.
+	QueueWaitHandler func(ctx context.Context, wait time.Duration)
+
+	// how long an idle worker waits for new tasks before exiting, 0 means exiting at once.
+	// 空闲的worker等待IdleTimeout后才退出，减少突发流量下goroutine的创建和销毁
+	IdleTimeout time.Duration
+	// number of idle workers kept waiting without timeout until the pool is closed.
+	MinIdleWorkers int32
+
+	// implementation of the task queue, defaults to QueueLocked.
+	// QueueSharded reduces lock contention when many goroutines submit at the same time.
+	QueueType QueueType
+	// QueueSharded only, defaults to GOMAXPROCS.
+	Shards int
+
+	// what to do when a task panics, defaults to PanicLog. Ignored if a handler is set by SetPanicHandler.
+	PanicPolicy PanicPolicy
+	// PanicCustom only.
+	PanicInfoHandler func(ctx context.Context, info *PanicInfo)
+	// record the submit site of tasks for PanicInfo, it costs a stack walk for each task.
+	RecordSubmitSite bool
+
+	// how workers choose between the priority lanes, defaults to SchedulingStrict.
+	Scheduling Scheduling
+	// SchedulingStrict only, defaults to 64.
+	StarvationLimit int
+	// SchedulingWeighted only, indexed by Priority, defaults to 8, 4, 1.
+	LaneWeights []int
 }
 
 // NewConfig creates a default Config.
@@ -0,0 +1,93 @@
+package gopool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrTaskPanic is wrapped by the error of a Future or Group whose task panicked.
+var ErrTaskPanic = errors.New("gopool: task panicked")
+
+// Future is the result of a task submitted by Submit.
+type Future[T any] struct {
+	done  chan struct{}
+	once  sync.Once
+	value T
+	err   error
+}
+
+// Submit executes f on p and returns a Future of its result.
+// The Future fails with the submit error if p rejects f, and with an error wrapping ErrTaskPanic if f panics.
+//
+//	future := gopool.Submit(p, ctx, func(ctx context.Context) (*User, error) {
+//	    return rpc.GetUser(ctx, id)
+//	})
+//	user, err := future.Get(ctx)
+func Submit[T any](p Pool, ctx context.Context, f func(ctx context.Context) (T, error)) *Future[T] {
+	return submitFuture(p, ctx, f, true)
+}
+
+// TrySubmit is like Submit but never blocks, the Future fails with ErrPoolFull if the queue is full.
+func TrySubmit[T any](p Pool, ctx context.Context, f func(ctx context.Context) (T, error)) *Future[T] {
+	return submitFuture(p, ctx, f, false)
+}
+
+func submitFuture[T any](p Pool, ctx context.Context, f func(ctx context.Context) (T, error), wait bool) *Future[T] {
+	fu := &Future[T]{done: make(chan struct{})}
+	submit := submitTask
+	if !wait {
+		submit = trySubmitTask
+	}
+	err := submit(p, ctx, func() {
+		var value T
+		err := runRecovered(func() error {
+			var err error
+			value, err = f(ctx)
+			return err
+		})
+		fu.complete(value, err)
+	}, func(err error) {
+		var zero T
+		fu.complete(zero, err)
+	})
+	if err != nil {
+		var zero T
+		fu.complete(zero, err)
+	}
+	return fu
+}
+
+func (fu *Future[T]) complete(value T, err error) {
+	fu.once.Do(func() {
+		fu.value, fu.err = value, err
+		close(fu.done)
+	})
+}
+
+// Done is closed when the task is finished.
+func (fu *Future[T]) Done() <-chan struct{} {
+	return fu.done
+}
+
+// Get waits for the task and returns its result, returns ctx.Err() if ctx is done first.
+func (fu *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-fu.done:
+		return fu.value, fu.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// runRecovered runs f and turns the panic into an error.
+func runRecovered(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrTaskPanic, r)
+		}
+	}()
+	return f()
+}
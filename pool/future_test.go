@@ -0,0 +1,66 @@
+package gopool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubmit(t *testing.T) {
+	p := NewPool("test", 10, NewConfig())
+	ctx := context.Background()
+	fu := Submit(p, ctx, func(ctx context.Context) (int, error) {
+		time.Sleep(10 * time.Millisecond)
+		return 1, nil
+	})
+	if v, err := fu.Get(ctx); err != nil || v != 1 {
+		t.Fatal("Get FAIL", v, err)
+	}
+
+	fu = Submit(p, ctx, func(ctx context.Context) (int, error) {
+		panic("test")
+	})
+	if _, err := fu.Get(ctx); !errors.Is(err, ErrTaskPanic) {
+		t.Fatal("panic must be returned as error", err)
+	}
+
+	fu = Submit(p, ctx, func(ctx context.Context) (int, error) {
+		time.Sleep(time.Second)
+		return 1, nil
+	})
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := fu.Get(timeout); err != context.DeadlineExceeded {
+		t.Fatal("Get must return when ctx is done", err)
+	}
+
+	p.Close()
+	fu = Submit(p, ctx, func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+	if _, err := fu.Get(ctx); err != ErrPoolClosed {
+		t.Fatal("rejected task must fail the future", err)
+	}
+}
+
+func TestTrySubmit(t *testing.T) {
+	config := NewConfig()
+	config.MaxQueueLength = 1
+	p := NewPool("test", 1, config)
+	block := make(chan struct{})
+	defer close(block)
+	started := make(chan struct{})
+	p.Go(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	p.Go(func() {})
+	fu := TrySubmit(p, context.Background(), func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+	if _, err := fu.Get(context.Background()); err != ErrPoolFull {
+		t.Fatal("TrySubmit must not block on a full queue", err)
+	}
+}
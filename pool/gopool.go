@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 )
 
 // defaultPool is the global default pool.
@@ -29,6 +30,36 @@ func CtxGo(ctx context.Context, f func()) {
 	defaultPool.CtxGo(ctx, f)
 }
 
+// CtxGoTimeout runs f via the global pool with a deadline of timeout after submission.
+func CtxGoTimeout(ctx context.Context, timeout time.Duration, f func(ctx context.Context)) {
+	defaultPool.CtxGoTimeout(ctx, timeout, f)
+}
+
+// CtxGoPriority runs f in the lane of priority of the global pool.
+func CtxGoPriority(ctx context.Context, priority Priority, f func()) {
+	defaultPool.CtxGoPriority(ctx, priority, f)
+}
+
+// TryGo submits f to the global pool without blocking.
+func TryGo(ctx context.Context, f func()) error {
+	return defaultPool.TryGo(ctx, f)
+}
+
+// GoAfter submits f to the global pool after d.
+func GoAfter(ctx context.Context, d time.Duration, f func()) *TimerHandle {
+	return defaultPool.GoAfter(ctx, d, f)
+}
+
+// GoAt submits f to the global pool at t.
+func GoAt(ctx context.Context, t time.Time, f func()) *TimerHandle {
+	return defaultPool.GoAt(ctx, t, f)
+}
+
+// GoEvery submits f to the global pool every interval until canceled.
+func GoEvery(ctx context.Context, interval time.Duration, f func()) *TimerHandle {
+	return defaultPool.GoEvery(ctx, interval, f)
+}
+
 // SetCap is not recommended to be called, this func changes the global pool's capacity which will affect other callers.
 func SetCap(cap int32) {
 	defaultPool.SetCap(cap)
@@ -55,6 +86,34 @@ func RegisterPool(p Pool) error {
 	return nil
 }
 
+// ShutdownAll shuts down all the registered pools concurrently and waits for them,
+// returns the first error. The global default pool is not included.
+func ShutdownAll(ctx context.Context) error {
+	var pools []Pool
+	poolMap.Range(func(key, value interface{}) bool {
+		p := value.(Pool)
+		p.Close()
+		pools = append(pools, p)
+		return true
+	})
+	var wg sync.WaitGroup
+	errs := make([]error, len(pools))
+	for i, p := range pools {
+		wg.Add(1)
+		go func(i int, p Pool) {
+			defer wg.Done()
+			errs[i] = p.Shutdown(ctx)
+		}(i, p)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("shutdown pool %s: %w", pools[i].Name(), err)
+		}
+	}
+	return nil
+}
+
 // GetPool gets the registered pool by name.
 // Returns nil if not registered.
 func GetPool(name string) Pool {
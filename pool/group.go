@@ -0,0 +1,109 @@
+package gopool
+
+import (
+	"context"
+	"sync"
+)
+
+// Group is like errgroup.Group but runs tasks on a pool and collects their results.
+// The ctx of the group is canceled when the first task returns an error or Wait returns.
+//
+//	g, ctx := gopool.NewGroup[*User](ctx, p, 10)
+//	for _, id := range ids {
+//	    id := id
+//	    g.Go(func(ctx context.Context) (*User, error) {
+//	        return rpc.GetUser(ctx, id)
+//	    })
+//	}
+//	users, err := g.Wait()
+type Group[T any] struct {
+	pool   Pool
+	ctx    context.Context
+	cancel context.CancelFunc
+	sem    chan struct{}
+	wg     sync.WaitGroup
+
+	lock    sync.Mutex
+	results []T
+	err     error
+}
+
+// NewGroup creates a Group running on p, at most limit tasks run at the same time, limit <= 0 means no limit.
+func NewGroup[T any](ctx context.Context, p Pool, limit int) (*Group[T], context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	g := &Group[T]{pool: p, ctx: ctx, cancel: cancel}
+	if limit > 0 {
+		g.sem = make(chan struct{}, limit)
+	}
+	return g, ctx
+}
+
+// Go submits f, it blocks if the concurrency limit is reached.
+// f is not executed if the group ctx is already canceled.
+func (g *Group[T]) Go(f func(ctx context.Context) (T, error)) {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		case <-g.ctx.Done():
+			g.setErr(g.ctx.Err())
+			return
+		}
+	}
+	g.lock.Lock()
+	i := len(g.results)
+	var zero T
+	g.results = append(g.results, zero)
+	g.lock.Unlock()
+
+	g.wg.Add(1)
+	done := func() {
+		if g.sem != nil {
+			<-g.sem
+		}
+		g.wg.Done()
+	}
+	err := submitTask(g.pool, g.ctx, func() {
+		defer done()
+		if g.ctx.Err() != nil {
+			return
+		}
+		var value T
+		err := runRecovered(func() error {
+			var err error
+			value, err = f(g.ctx)
+			return err
+		})
+		if err != nil {
+			g.setErr(err)
+			return
+		}
+		g.lock.Lock()
+		g.results[i] = value
+		g.lock.Unlock()
+	}, func(err error) {
+		g.setErr(err)
+		done()
+	})
+	if err != nil {
+		g.setErr(err)
+		done()
+	}
+}
+
+func (g *Group[T]) setErr(err error) {
+	g.lock.Lock()
+	if g.err == nil {
+		g.err = err
+		g.cancel()
+	}
+	g.lock.Unlock()
+}
+
+// Wait waits for all the tasks, returns the results in the order of Go and the first error.
+func (g *Group[T]) Wait() ([]T, error) {
+	g.wg.Wait()
+	g.cancel()
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.results, g.err
+}
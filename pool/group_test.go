@@ -0,0 +1,63 @@
+package gopool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	p := NewPool("test", 100, NewConfig())
+	g, _ := NewGroup[int](context.Background(), p, 3)
+	var running, maxRunning int32
+	for i := 0; i < 20; i++ {
+		i := i
+		g.Go(func(ctx context.Context) (int, error) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return i * i, nil
+		})
+	}
+	results, err := g.Wait()
+	if err != nil || len(results) != 20 {
+		t.Fatal("Wait FAIL", err, results)
+	}
+	for i, v := range results {
+		if v != i*i {
+			t.Fatal("results must be in order", results)
+		}
+	}
+	if maxRunning > 3 {
+		t.Fatal("concurrency limit exceeded", maxRunning)
+	}
+
+	// 第一个错误取消其他任务
+	g, ctx := NewGroup[int](context.Background(), p, 0)
+	expected := errors.New("rpc error")
+	g.Go(func(ctx context.Context) (int, error) {
+		return 0, expected
+	})
+	g.Go(func(ctx context.Context) (int, error) {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(time.Second):
+			return 1, nil
+		}
+	})
+	if _, err = g.Wait(); err != expected {
+		t.Fatal("Wait must return the first error", err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("group ctx must be canceled")
+	}
+}
@@ -0,0 +1,217 @@
+package gopool
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/zsyu9779/myUtil/hash"
+)
+
+const (
+	defaultKeyedLanes = 1024
+	defaultKeyedBatch = 64
+	keyedShards       = 64
+)
+
+// ErrLaneFull is returned by KeyedExecutor.Go when the lane of the key is full.
+var ErrLaneFull = errors.New("gopool: keyed lane is full")
+
+type KeyedOptions struct {
+	// number of serial lanes, keys are hashed to lanes so different keys may share a lane, defaults to 1024
+	Lanes int
+	// max number of queued tasks in a lane, 0 means unbounded
+	MaxLaneLength int
+	// number of tasks a lane runs before giving the worker back to the pool, defaults to 64
+	Batch int
+}
+
+// KeyedExecutor runs the tasks of the same key one by one in submission order on a pool,
+// while tasks of different keys run in parallel. A lane only exists while it has tasks.
+//
+//	e := gopool.NewKeyedExecutor(p, gopool.KeyedOptions{MaxLaneLength: 100})
+//	e.Go(ctx, deviceID, func() {
+//	    handle(msg)
+//	})
+type KeyedExecutor struct {
+	pool    Pool
+	options KeyedOptions
+	shards  [keyedShards]keyedShard
+}
+
+type keyedShard struct {
+	sync.Mutex
+	lanes map[uint32]*serialLane
+}
+
+type keyedTask struct {
+	ctx  context.Context
+	f    func()
+	next *keyedTask
+}
+
+type serialLane struct {
+	head    *keyedTask
+	tail    *keyedTask
+	length  int
+	running bool
+	// set while the first task of the lane is submitting the drain task
+	starting *laneStart
+}
+
+// laneStart is the result of starting a lane, tasks queued meanwhile wait for it.
+type laneStart struct {
+	done chan struct{}
+	err  error
+}
+
+func (l *serialLane) pop() *keyedTask {
+	t := l.head
+	if t != nil {
+		l.head = t.next
+		if l.head == nil {
+			l.tail = nil
+		}
+		l.length--
+	}
+	return t
+}
+
+func NewKeyedExecutor(p Pool, options KeyedOptions) *KeyedExecutor {
+	if options.Lanes <= 0 {
+		options.Lanes = defaultKeyedLanes
+	}
+	if options.Batch <= 0 {
+		options.Batch = defaultKeyedBatch
+	}
+	e := &KeyedExecutor{pool: p, options: options}
+	for i := range e.shards {
+		e.shards[i].lanes = make(map[uint32]*serialLane)
+	}
+	return e
+}
+
+// Go queues f in the lane of key, f is skipped if ctx is done before it starts.
+// Returns ErrLaneFull if the lane has MaxLaneLength tasks, or the error of the pool if it can't start the lane,
+// then f is not queued. The calls queued while the lane is starting get the same error.
+func (e *KeyedExecutor) Go(ctx context.Context, key string, f func()) error {
+	id := hash.Murmurhash3([]byte(key), 0) % uint32(e.options.Lanes)
+	s := &e.shards[id%keyedShards]
+	s.Lock()
+	l := s.lanes[id]
+	if l == nil {
+		l = &serialLane{}
+		s.lanes[id] = l
+	}
+	if e.options.MaxLaneLength > 0 && l.length >= e.options.MaxLaneLength {
+		s.Unlock()
+		return ErrLaneFull
+	}
+	t := &keyedTask{ctx: ctx, f: f}
+	if l.tail == nil {
+		l.head = t
+	} else {
+		l.tail.next = t
+	}
+	l.tail = t
+	l.length++
+	if l.running {
+		st := l.starting
+		s.Unlock()
+		if st == nil {
+			return nil
+		}
+		<-st.done
+		return st.err
+	}
+	l.running = true
+	st := &laneStart{done: make(chan struct{})}
+	l.starting = st
+	s.Unlock()
+
+	err := submitTask(e.pool, context.Background(), func() {
+		e.drain(s, id, l)
+	}, e.runDrain(s, id, l))
+	s.Lock()
+	l.starting = nil
+	if err != nil {
+		// a lane only exists while it's running, so all the tasks are queued by this call
+		// and the calls waiting for st, they get err and may retry
+		l.head, l.tail, l.length = nil, nil, 0
+		l.running = false
+		delete(s.lanes, id)
+	}
+	st.err = err
+	s.Unlock()
+	close(st.done)
+	return err
+}
+
+// runDrain is called if the drain task of l is dropped by the pool after accepted (PolicyDropOldest or TaskTimeout),
+// it drains l in the current goroutine like PolicyCallerRuns, so that accepted tasks are never stranded.
+func (e *KeyedExecutor) runDrain(s *keyedShard, id uint32, l *serialLane) func(error) {
+	return func(error) {
+		ip, ok := e.pool.(*pool)
+		if !ok {
+			e.drain(s, id, l)
+			return
+		}
+		t := ip.newTask(context.Background(), func() {
+			e.drain(s, id, l)
+		}, nil)
+		ip.runTask(t)
+		t.Recycle()
+	}
+}
+
+// drain runs at most Batch tasks of l, then resubmits itself so that other lanes get workers.
+// If the pool can't take it right away, drain goes on in the current worker instead of blocking on its own pool.
+// The lane is removed once it's empty.
+func (e *KeyedExecutor) drain(s *keyedShard, id uint32, l *serialLane) {
+	finished := false
+	defer func() {
+		// a task panicked, go on with the rest in a new pool task or right here
+		if !finished {
+			if err := trySubmitTask(e.pool, context.Background(), func() {
+				e.drain(s, id, l)
+			}, e.runDrain(s, id, l)); err != nil {
+				e.runDrain(s, id, l)(err)
+			}
+		}
+	}()
+	for {
+		for i := 0; i < e.options.Batch; i++ {
+			s.Lock()
+			t := l.pop()
+			if t == nil {
+				l.running = false
+				delete(s.lanes, id)
+				s.Unlock()
+				finished = true
+				return
+			}
+			s.Unlock()
+			if t.ctx.Err() == nil {
+				t.f()
+			}
+		}
+		if trySubmitTask(e.pool, context.Background(), func() {
+			e.drain(s, id, l)
+		}, e.runDrain(s, id, l)) == nil {
+			finished = true
+			return
+		}
+	}
+}
+
+// LaneCount returns the number of lanes that have tasks.
+func (e *KeyedExecutor) LaneCount() int {
+	n := 0
+	for i := range e.shards {
+		s := &e.shards[i]
+		s.Lock()
+		n += len(s.lanes)
+		s.Unlock()
+	}
+	return n
+}
@@ -0,0 +1,197 @@
+package gopool
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeyedExecutor(t *testing.T) {
+	p := NewPool("test", 16, NewConfig())
+	e := NewKeyedExecutor(p, KeyedOptions{Batch: 3})
+	var lock sync.Mutex
+	got := make(map[string][]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		for k := 0; k < 10; k++ {
+			key := "device_" + strconv.Itoa(k)
+			i := i
+			wg.Add(1)
+			err := e.Go(context.Background(), key, func() {
+				defer wg.Done()
+				lock.Lock()
+				got[key] = append(got[key], i)
+				lock.Unlock()
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	wg.Wait()
+	for key, seq := range got {
+		if len(seq) != 50 {
+			t.Fatal("tasks lost", key, len(seq))
+		}
+		for i, v := range seq {
+			if v != i {
+				t.Fatal("tasks of the same key must run in order", key, seq)
+			}
+		}
+	}
+	for i := 0; i < 100 && e.LaneCount() > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if e.LaneCount() != 0 {
+		t.Fatal("idle lanes must be removed", e.LaneCount())
+	}
+}
+
+func TestKeyedExecutor_LaneFull(t *testing.T) {
+	p := NewPool("test", 16, NewConfig())
+	e := NewKeyedExecutor(p, KeyedOptions{MaxLaneLength: 2})
+	block := make(chan struct{})
+	started := make(chan struct{})
+	ctx := context.Background()
+	e.Go(ctx, "key", func() {
+		close(started)
+		<-block
+	})
+	<-started
+	e.Go(ctx, "key", func() {})
+	e.Go(ctx, "key", func() {})
+	if err := e.Go(ctx, "key", func() {}); err != ErrLaneFull {
+		t.Fatal("lane must be full", err)
+	}
+	if err := e.Go(ctx, "other", func() {}); err != nil {
+		t.Fatal("other keys must not be affected", err)
+	}
+	close(block)
+}
+
+func TestKeyedExecutor_BoundedQueue(t *testing.T) {
+	// a lane resubmitting itself must not block its worker on the full queue
+	config := NewConfig()
+	config.MaxQueueLength = 1
+	p := NewPool("test", 1, config)
+	e := NewKeyedExecutor(p, KeyedOptions{Batch: 1})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, key := range []string{"a", "b"} {
+			wg.Add(1)
+			if err := e.Go(context.Background(), key, wg.Done); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock", p.WorkerCount(), p.TaskCount())
+	}
+}
+
+func TestKeyedExecutor_Rejected(t *testing.T) {
+	p := NewPool("test", 1, NewConfig())
+	e := NewKeyedExecutor(p, KeyedOptions{})
+	p.Close()
+	ran := false
+	if err := e.Go(context.Background(), "key", func() { ran = true }); err != ErrPoolClosed {
+		t.Fatal("Go must fail on a closed pool", err)
+	}
+	if e.LaneCount() != 0 {
+		t.Fatal("rejected task must not stay in the lane", e.LaneCount())
+	}
+	if ran {
+		t.Fatal("rejected task must not run")
+	}
+}
+
+func TestKeyedExecutor_RejectedConcurrent(t *testing.T) {
+	// the first call blocks on the full queue, the calls queued behind it must get its error
+	config := NewConfig()
+	config.MaxQueueLength = 1
+	p := NewPool("test", 1, config)
+	block := make(chan struct{})
+	defer close(block)
+	started := make(chan struct{})
+	p.Go(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	p.Go(func() {})
+
+	e := NewKeyedExecutor(p, KeyedOptions{})
+	var ran int32
+	errs := make(chan error, 5)
+	go func() {
+		errs <- e.Go(context.Background(), "key", func() { atomic.AddInt32(&ran, 1) })
+	}()
+	for i := 0; i < 100 && e.LaneCount() == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		go func() {
+			errs <- e.Go(context.Background(), "key", func() { atomic.AddInt32(&ran, 1) })
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	p.Close()
+	for i := 0; i < 5; i++ {
+		if err := <-errs; err != ErrPoolClosed {
+			t.Fatal("calls queued behind a rejected start must fail", err)
+		}
+	}
+	if e.LaneCount() != 0 || atomic.LoadInt32(&ran) != 0 {
+		t.Fatal("rejected tasks must be removed", e.LaneCount(), ran)
+	}
+}
+
+func TestKeyedExecutor_DrainSkipped(t *testing.T) {
+	// the drain task is skipped by TaskTimeout after accepted, the tasks must still run
+	config := NewConfig()
+	config.TaskTimeout = 10 * time.Millisecond
+	p := NewPool("test", 1, config)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	p.Go(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	e := NewKeyedExecutor(p, KeyedOptions{})
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		if err := e.Go(context.Background(), "key", wg.Done); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(30 * time.Millisecond)
+	close(block)
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("tasks are stranded in the lane")
+	}
+	for i := 0; i < 100 && e.LaneCount() > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if e.LaneCount() != 0 {
+		t.Fatal("idle lanes must be removed", e.LaneCount())
+	}
+}
@@ -0,0 +1,86 @@
+package gopool
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// PanicPolicy decides what to do when a task panics and no handler is set by SetPanicHandler.
+type PanicPolicy int
+
+const (
+	// PanicLog logs the panic with the stack trace and keeps the worker running.
+	PanicLog PanicPolicy = iota
+	// PanicCrash logs the panic and exits the process.
+	PanicCrash
+	// PanicCustom calls Config.PanicInfoHandler, falls back to PanicLog if it's nil.
+	PanicCustom
+)
+
+// max frames recorded for the submit site
+const submitSiteDepth = 8
+
+var pkgPrefix = reflect.TypeOf(pool{}).PkgPath() + "."
+
+// PanicInfo describes a recovered panic of a task.
+type PanicInfo struct {
+	Pool  string
+	Value interface{}
+	Stack []byte
+	// file:line of the code that submitted the task, only recorded if Config.RecordSubmitSite is true
+	SubmitSite string
+}
+
+func (i *PanicInfo) String() string {
+	site := i.SubmitSite
+	if site == "" {
+		site = "unknown"
+	}
+	return fmt.Sprintf("GOPOOL: panic in pool: %s: %v: submitted at %s\n%s", i.Pool, i.Value, site, i.Stack)
+}
+
+// recordSubmitSite saves the call stack of the submitter into t.
+func (t *task) recordSubmitSite() {
+	// skip runtime.Callers and recordSubmitSite
+	t.npcs = runtime.Callers(2, t.pcs[:])
+}
+
+// submitSite returns the first frame out of this package, test files of this package count as outside.
+func (t *task) submitSite() string {
+	if t.npcs == 0 {
+		return ""
+	}
+	frames := runtime.CallersFrames(t.pcs[:t.npcs])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, pkgPrefix) || strings.HasSuffix(frame.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// handlePanic must be called in the deferred function recovering the panic, so that the stack contains the panic.
+func (p *pool) handlePanic(t *task, r interface{}) {
+	if p.panicHandler != nil {
+		p.panicHandler(t.ctx, r)
+		return
+	}
+	info := &PanicInfo{Pool: p.name, Value: r, Stack: debug.Stack(), SubmitSite: t.submitSite()}
+	switch p.config.PanicPolicy {
+	case PanicCrash:
+		log.Fatal(info.String())
+	case PanicCustom:
+		if p.config.PanicInfoHandler != nil {
+			p.config.PanicInfoHandler(t.ctx, info)
+			return
+		}
+	}
+	log.Print(info.String())
+}
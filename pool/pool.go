@@ -2,10 +2,26 @@ package gopool
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
+var (
+	// ErrPoolFull is returned by TryGo when the task queue is full.
+	ErrPoolFull = errors.New("gopool: task queue is full")
+	// ErrPoolClosed is returned by TryGo after the pool is closed.
+	ErrPoolClosed = errors.New("gopool: pool is closed")
+	// ErrTaskDropped is the error of a Future whose task is dropped by PolicyDropOldest.
+	ErrTaskDropped = errors.New("gopool: task is dropped")
+	// ErrTaskTimeout is the skip reason of a task waiting longer than Config.TaskTimeout.
+	ErrTaskTimeout = errors.New("gopool: task waited too long in queue")
+)
+
+// interval to check whether all tasks are done in Shutdown
+const shutdownPollInterval = 10 * time.Millisecond
+
 type Pool interface {
 	// Name returns the corresponding pool name.
 	Name() string
@@ -14,13 +30,38 @@ type Pool interface {
 	// Go executes f.
 	Go(f func())
 	// CtxGo executes f and accepts the context.
+	// When the queue is full, f is handled by Config.RejectPolicy and may be dropped.
 	CtxGo(ctx context.Context, f func())
-	// SetPanicHandler sets the panic handler.
+	// CtxGoTimeout is like CtxGo but f has a deadline of timeout after submission. f is skipped with
+	// context.DeadlineExceeded if it doesn't start before the deadline, and the ctx passed to f is done at the deadline.
+	CtxGoTimeout(ctx context.Context, timeout time.Duration, f func(ctx context.Context))
+	// CtxGoPriority is like CtxGo but queues f in the lane of priority.
+	CtxGoPriority(ctx context.Context, priority Priority, f func())
+	// TryGo is like CtxGo but never blocks and reports whether f is accepted.
+	// It returns ErrPoolFull if the queue is full and the policy is PolicyBlock, PolicyReject or PolicyCallerRuns.
+	TryGo(ctx context.Context, f func()) error
+	// GoAfter submits f after d, GoAt submits f at t, and GoEvery submits f every interval until canceled.
+	// The tasks are kept in one timer heap of the pool and canceled when the pool is closed.
+	// A periodic task is also canceled when ctx is done.
+	// A due run is submitted like TryGo and never runs on the scheduler, if the pool rejects it
+	// (the queue is full under any policy but PolicyDropOldest, or the pool is closed) the run is dropped, counted by TimerHandle.Dropped and reported to Config.SkipHandler.
+	GoAfter(ctx context.Context, d time.Duration, f func()) *TimerHandle
+	GoAt(ctx context.Context, t time.Time, f func()) *TimerHandle
+	GoEvery(ctx context.Context, interval time.Duration, f func()) *TimerHandle
+	// SetPanicHandler sets the panic handler, it takes precedence over Config.PanicPolicy.
 	SetPanicHandler(f func(context.Context, interface{}))
 	// WorkerCount returns the number of running workers
 	WorkerCount() int32
 
 	TaskCount() int32
+	// Stats returns a snapshot of the counters and histograms of the pool.
+	Stats() Stats
+
+	// Close stops accepting new tasks, queued tasks will still be executed.
+	Close()
+	// Shutdown closes the pool and waits until all queued and running tasks are done,
+	// returns ctx.Err() if ctx is done first.
+	Shutdown(ctx context.Context) error
 }
 
 var taskPool sync.Pool
@@ -32,6 +73,13 @@ func init() {
 type task struct {
 	ctx context.Context
 	f   func()
+	// called instead of f if the task is dropped, may be nil
+	discard func(err error)
+	// when the task is queued
+	submitTime time.Time
+	// call stack of the submitter, only recorded if Config.RecordSubmitSite is true
+	pcs  [submitSiteDepth]uintptr
+	npcs int
 
 	next *task
 }
@@ -39,6 +87,9 @@ type task struct {
 func (t *task) zero() {
 	t.ctx = nil
 	t.f = nil
+	t.discard = nil
+	t.submitTime = time.Time{}
+	t.npcs = 0
 	t.next = nil
 }
 
@@ -51,12 +102,6 @@ func newTask() interface{} {
 	return &task{}
 }
 
-type taskList struct {
-	sync.Mutex
-	taskHead *task
-	taskTail *task
-}
-
 type pool struct {
 	// The name of the pool
 	name string
@@ -65,25 +110,57 @@ type pool struct {
 	cap int32
 	// Configuration information
 	config *Config
-	// linked list of tasks
-	taskHead  *task
-	taskTail  *task
-	taskLock  sync.Mutex
+	// queued tasks, one linked list for each priority
+	queue taskQueue
+	// incremented before a task is pushed and decremented after it's popped
 	taskCount int32
+	// one token for each queued task when MaxQueueLength > 0
+	slots chan struct{}
+
+	closed    int32
+	closeOnce sync.Once
+	closeCh   chan struct{}
+	// number of submit calls in progress, Shutdown waits for them
+	submitting int32
 
 	// Record the number of running workers
 	workerCount int32
+	// used to give workers different home shards
+	workerSeq uint32
+	// number of parked workers and how many of them wait without timeout
+	idleCount int32
+	warmCount int32
+	// wakes up parked workers, a pending token makes one worker recheck the queue
+	wakeup chan struct{}
 
 	// This method will be called when the worker panic
 	panicHandler func(context.Context, interface{})
+
+	stats poolStats
+
+	// delayed and periodic tasks
+	timers *scheduler
 }
 
 // NewPool creates a new pool with the given name, cap and config.
 func NewPool(name string, cap int32, config *Config) Pool {
 	p := &pool{
-		name:   name,
-		cap:    cap,
-		config: config,
+		name:    name,
+		cap:     cap,
+		config:  config,
+		closeCh: make(chan struct{}),
+		queue:   newTaskQueue(config),
+	}
+	p.timers = newScheduler(p)
+	if config.IdleTimeout > 0 || config.MinIdleWorkers > 0 {
+		wakeups := cap
+		if wakeups < 1 {
+			wakeups = 1
+		}
+		p.wakeup = make(chan struct{}, wakeups)
+	}
+	if config.MaxQueueLength > 0 {
+		p.slots = make(chan struct{}, config.MaxQueueLength)
 	}
 	return p
 }
@@ -101,19 +178,108 @@ func (p *pool) Go(f func()) {
 }
 
 func (p *pool) CtxGo(ctx context.Context, f func()) {
+	p.submit(ctx, PriorityNormal, f, nil, true)
+}
+
+func (p *pool) CtxGoTimeout(ctx context.Context, timeout time.Duration, f func(ctx context.Context)) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	// cancel is called when f returns, or when f is rejected, dropped or skipped
+	err := p.submit(ctx, PriorityNormal, func() {
+		defer cancel()
+		f(ctx)
+	}, func(error) {
+		cancel()
+	}, true)
+	if err != nil {
+		cancel()
+	}
+}
+
+func (p *pool) CtxGoPriority(ctx context.Context, priority Priority, f func()) {
+	p.submit(ctx, priority, f, nil, true)
+}
+
+func (p *pool) TryGo(ctx context.Context, f func()) error {
+	return p.submit(ctx, PriorityNormal, f, nil, false)
+}
+
+func (p *pool) submit(ctx context.Context, priority Priority, f func(), discard func(error), wait bool) error {
+	err := p.enqueue(ctx, priority, f, discard, wait)
+	if err != nil {
+		atomic.AddUint64(&p.stats.rejected, 1)
+	} else {
+		atomic.AddUint64(&p.stats.submitted, 1)
+	}
+	return err
+}
+
+func (p *pool) enqueue(ctx context.Context, priority Priority, f func(), discard func(error), wait bool) error {
+	atomic.AddInt32(&p.submitting, 1)
+	defer atomic.AddInt32(&p.submitting, -1)
+	if atomic.LoadInt32(&p.closed) == 1 {
+		return ErrPoolClosed
+	}
+	if p.slots != nil {
+		select {
+		case p.slots <- struct{}{}:
+		default:
+			switch p.config.RejectPolicy {
+			case PolicyBlock:
+				if !wait {
+					return ErrPoolFull
+				}
+				select {
+				case p.slots <- struct{}{}:
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-p.closeCh:
+					return ErrPoolClosed
+				}
+			case PolicyDropOldest:
+				if p.dropOldest(ctx, priority, f, discard) {
+					return nil
+				}
+			case PolicyCallerRuns:
+				if !wait {
+					// TryGo never blocks, so it doesn't run f in the caller
+					return ErrPoolFull
+				}
+				t := p.newTask(ctx, f, discard)
+				p.runTask(t)
+				t.Recycle()
+				return nil
+			default:
+				return ErrPoolFull
+			}
+		}
+	}
+	t := p.newTask(ctx, f, discard)
+	atomic.AddInt32(&p.taskCount, 1)
+	p.queue.push(t, priority)
+	p.scale()
+	return nil
+}
+
+func (p *pool) newTask(ctx context.Context, f func(), discard func(error)) *task {
 	t := taskPool.Get().(*task)
 	t.ctx = ctx
 	t.f = f
-	p.taskLock.Lock()
-	if p.taskHead == nil {
-		p.taskHead = t
-		p.taskTail = t
-	} else {
-		p.taskTail.next = t
-		p.taskTail = t
+	t.discard = discard
+	t.submitTime = time.Now()
+	if p.config.RecordSubmitSite {
+		t.recordSubmitSite()
+	}
+	return t
+}
+
+func (p *pool) scale() {
+	if atomic.LoadInt32(&p.idleCount) > 0 {
+		select {
+		case p.wakeup <- struct{}{}:
+			return
+		default:
+		}
 	}
-	p.taskLock.Unlock()
-	atomic.AddInt32(&p.taskCount, 1)
 	// The following two conditions are met:
 	// 1. the number of tasks is greater than the threshold.
 	// 2. The current number of workers is less than the upper limit p.cap.
@@ -125,10 +291,37 @@ func (p *pool) CtxGo(ctx context.Context, f func()) {
 		p.incWorkerCount() //增加计数
 		w := workerPool.Get().(*worker)
 		w.pool = p
+		w.shard = int(atomic.AddUint32(&p.workerSeq, 1))
 		w.run()
 	}
 }
 
+// dropOldest replaces the oldest task of the lowest priority lane with a new task, the new task takes over its slot.
+// Returns false if the queue became empty meanwhile, then the caller gets a slot normally.
+func (p *pool) dropOldest(ctx context.Context, priority Priority, f func(), discard func(error)) bool {
+	for {
+		select {
+		case p.slots <- struct{}{}:
+			return false
+		default:
+		}
+		t := p.newTask(ctx, f, discard)
+		old := p.queue.dropOldest()
+		if old == nil {
+			t.Recycle()
+			continue
+		}
+		p.queue.push(t, priority)
+		atomic.AddUint64(&p.stats.rejected, 1)
+		if old.discard != nil {
+			old.discard(ErrTaskDropped)
+		}
+		old.Recycle()
+		p.scale()
+		return true
+	}
+}
+
 // SetPanicHandler the func here will be called after the panic has been recovered.
 // 自定义recover方法
 func (p *pool) SetPanicHandler(f func(context.Context, interface{})) {
@@ -143,6 +336,72 @@ func (p *pool) TaskCount() int32 {
 	return atomic.LoadInt32(&p.taskCount)
 }
 
+// execute runs a dequeued task unless its ctx is done or it waited longer than TaskTimeout.
+func (p *pool) execute(t *task) {
+	wait := time.Since(t.submitTime)
+	var err error
+	if t.ctx != nil {
+		err = t.ctx.Err()
+	}
+	if err == nil && p.config.TaskTimeout > 0 && wait > p.config.TaskTimeout {
+		err = ErrTaskTimeout
+	}
+	if err != nil {
+		atomic.AddUint64(&p.stats.skipped, 1)
+		if t.discard != nil {
+			t.discard(err)
+		}
+		if p.config.SkipHandler != nil {
+			p.config.SkipHandler(t.ctx, err)
+		}
+		return
+	}
+	p.stats.queueWait.observe(wait)
+	if p.config.QueueWaitHandler != nil {
+		p.config.QueueWaitHandler(t.ctx, wait)
+	}
+	p.runTask(t)
+}
+
+// submitTask submits f in blocking mode, discard is called if f is dropped after accepted.
+func submitTask(p Pool, ctx context.Context, f func(), discard func(error)) error {
+	if ip, ok := p.(*pool); ok {
+		return ip.submit(ctx, PriorityNormal, f, discard, true)
+	}
+	return p.TryGo(ctx, f)
+}
+
+// trySubmitTask is like submitTask but never blocks, it's used by tasks resubmitting themselves from a worker.
+func trySubmitTask(p Pool, ctx context.Context, f func(), discard func(error)) error {
+	if ip, ok := p.(*pool); ok {
+		return ip.submit(ctx, PriorityNormal, f, discard, false)
+	}
+	return p.TryGo(ctx, f)
+}
+
+func (p *pool) Close() {
+	p.closeOnce.Do(func() {
+		atomic.StoreInt32(&p.closed, 1)
+		close(p.closeCh)
+	})
+}
+
+func (p *pool) Shutdown(ctx context.Context) error {
+	p.Close()
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if atomic.LoadInt32(&p.submitting) == 0 && p.TaskCount() == 0 && p.WorkerCount() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
 func (p *pool) incWorkerCount() {
 	atomic.AddInt32(&p.workerCount, 1)
 }
@@ -1,7 +1,10 @@
 package gopool
 
 import (
+	"context"
+	"reflect"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -50,9 +53,371 @@ func TestPool(t *testing.T) {
 	}
 }
 
+func TestPoolSharded(t *testing.T) {
+	config := NewConfig()
+	config.QueueType = QueueSharded
+	config.Shards = 4
+	p := NewPool("test", 8, config)
+	var n int32
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				p.Go(func() {
+					atomic.AddInt32(&n, 1)
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n != 16000 {
+		t.Fatal("all tasks must be executed", n)
+	}
+}
+
 func TestPoolPanic(t *testing.T) {
+	// 默认只打日志，不会让进程退出
 	p := NewPool("test", 100, NewConfig())
 	p.Go(testPanicFunc)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if p.Stats().Panicked != 1 {
+		t.Fatal("panic must be counted", p.Stats().Panicked)
+	}
+
+	var info *PanicInfo
+	config := NewConfig()
+	config.PanicPolicy = PanicCustom
+	config.RecordSubmitSite = true
+	config.PanicInfoHandler = func(ctx context.Context, i *PanicInfo) {
+		info = i
+	}
+	p = NewPool("test_panic", 100, config)
+	p.Go(testPanicFunc)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if info == nil || info.Pool != "test_panic" || info.Value != "test" {
+		t.Fatalf("panic info not expected: %+v", info)
+	}
+	if !strings.Contains(info.SubmitSite, "pool_test.go") || !strings.Contains(string(info.Stack), "testPanicFunc") {
+		t.Fatal("submit site or stack not expected", info.SubmitSite, string(info.Stack))
+	}
+}
+
+func TestPoolQueueFull(t *testing.T) {
+	newFullPool := func(policy RejectPolicy) (Pool, chan struct{}) {
+		config := NewConfig()
+		config.MaxQueueLength = 2
+		config.RejectPolicy = policy
+		p := NewPool("test", 1, config)
+		block := make(chan struct{})
+		started := make(chan struct{})
+		p.Go(func() {
+			close(started)
+			<-block
+		})
+		<-started
+		p.Go(func() {})
+		p.Go(func() {})
+		return p, block
+	}
+
+	// PolicyBlock
+	p, block := newFullPool(PolicyBlock)
+	if err := p.TryGo(context.Background(), func() {}); err != ErrPoolFull {
+		t.Fatal("TryGo must not block", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	p.CtxGo(ctx, func() { t.Error("task must be dropped when ctx is done") })
+	done := make(chan struct{})
+	go func() {
+		p.Go(func() { close(done) })
+	}()
+	close(block)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("blocked task must run after the queue has room")
+	}
+
+	// PolicyReject
+	p, block = newFullPool(PolicyReject)
+	if err := p.TryGo(context.Background(), func() {}); err != ErrPoolFull {
+		t.Fatal("PolicyReject must return ErrPoolFull", err)
+	}
+	close(block)
+
+	// PolicyDropOldest
+	config := NewConfig()
+	config.MaxQueueLength = 2
+	config.RejectPolicy = PolicyDropOldest
+	p = NewPool("test", 1, config)
+	block = make(chan struct{})
+	started := make(chan struct{})
+	p.Go(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	var ran []int
+	var lock sync.Mutex
+	for i := 0; i < 4; i++ {
+		i := i
+		err := p.TryGo(context.Background(), func() {
+			lock.Lock()
+			ran = append(ran, i)
+			lock.Unlock()
+		})
+		if err != nil {
+			t.Fatal("PolicyDropOldest must accept new task", err)
+		}
+	}
+	close(block)
+	for p.TaskCount() > 0 || p.WorkerCount() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if len(ran) != 2 || ran[0] != 2 || ran[1] != 3 {
+		t.Fatal("oldest tasks must be dropped", ran)
+	}
+
+	// PolicyCallerRuns
+	p, block = newFullPool(PolicyCallerRuns)
+	callerRuns := false
+	p.CtxGo(context.Background(), func() { callerRuns = true })
+	if !callerRuns {
+		t.Fatal("PolicyCallerRuns must run task in caller")
+	}
+	if err := p.TryGo(context.Background(), func() { callerRuns = false }); err != ErrPoolFull || !callerRuns {
+		t.Fatal("TryGo must not run task in caller", err)
+	}
+	close(block)
+}
+
+func TestPoolSkip(t *testing.T) {
+	var skipped []error
+	var waits []time.Duration
+	var lock sync.Mutex
+	config := NewConfig()
+	config.TaskTimeout = 50 * time.Millisecond
+	config.SkipHandler = func(ctx context.Context, err error) {
+		lock.Lock()
+		skipped = append(skipped, err)
+		lock.Unlock()
+	}
+	config.QueueWaitHandler = func(ctx context.Context, wait time.Duration) {
+		lock.Lock()
+		waits = append(waits, wait)
+		lock.Unlock()
+	}
+	p := NewPool("test", 1, config)
+	block := make(chan struct{})
+	p.Go(func() { <-block })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.CtxGo(ctx, func() { t.Error("task with canceled ctx must be skipped") })
+	cancel()
+	p.Go(func() { t.Error("task waited too long must be skipped") })
+	fu := Submit(p, context.Background(), func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+	time.Sleep(100 * time.Millisecond)
+	close(block)
+	if _, err := fu.Get(context.Background()); err != ErrTaskTimeout {
+		t.Fatal("skipped future must fail", err)
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 3 || skipped[0] != context.Canceled || skipped[1] != ErrTaskTimeout {
+		t.Fatal("skipped tasks not expected", skipped)
+	}
+	if len(waits) != 1 {
+		t.Fatal("queue wait must be reported for executed tasks", waits)
+	}
+}
+
+func TestPoolCtxGoTimeout(t *testing.T) {
+	var skipped int32
+	config := NewConfig()
+	config.SkipHandler = func(ctx context.Context, err error) {
+		if err == context.DeadlineExceeded {
+			atomic.AddInt32(&skipped, 1)
+		}
+	}
+	p := NewPool("test", 1, config)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	p.Go(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	// not started before its deadline
+	ran := false
+	p.CtxGoTimeout(context.Background(), 10*time.Millisecond, func(ctx context.Context) {
+		ran = true
+	})
+	// started in time, the ctx bounds the execution
+	done := make(chan error)
+	p.CtxGoTimeout(context.Background(), time.Second, func(ctx context.Context) {
+		<-ctx.Done()
+		done <- ctx.Err()
+	})
+	time.Sleep(30 * time.Millisecond)
+	close(block)
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Fatal("ctx of f must be done at the deadline", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("ctx of f has no deadline")
+	}
+	if ran || atomic.LoadInt32(&skipped) != 1 {
+		t.Fatal("task must be skipped after its deadline", ran, skipped)
+	}
+}
+
+func runInOrder(t *testing.T, config *Config, priorities []Priority) []Priority {
+	p := NewPool("test", 1, config)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	p.Go(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	var order []Priority
+	for _, priority := range priorities {
+		priority := priority
+		p.CtxGoPriority(context.Background(), priority, func() {
+			order = append(order, priority)
+		})
+	}
+	close(block)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+func TestPoolPriority(t *testing.T) {
+	order := runInOrder(t, NewConfig(), []Priority{PriorityLow, PriorityNormal, PriorityHigh, PriorityLow, PriorityHigh})
+	expected := []Priority{PriorityHigh, PriorityHigh, PriorityNormal, PriorityLow, PriorityLow}
+	if !reflect.DeepEqual(order, expected) {
+		t.Fatal("strict order not expected", order)
+	}
+
+	// 低优先级被跳过StarvationLimit次后执行一次
+	config := NewConfig()
+	config.StarvationLimit = 2
+	order = runInOrder(t, config, []Priority{PriorityLow, PriorityHigh, PriorityHigh, PriorityHigh, PriorityHigh})
+	expected = []Priority{PriorityHigh, PriorityHigh, PriorityLow, PriorityHigh, PriorityHigh}
+	if !reflect.DeepEqual(order, expected) {
+		t.Fatal("low priority lane starved", order)
+	}
+
+	config = NewConfig()
+	config.Scheduling = SchedulingWeighted
+	config.LaneWeights = []int{2, 1, 1}
+	order = runInOrder(t, config, []Priority{PriorityLow, PriorityLow, PriorityLow, PriorityHigh, PriorityHigh, PriorityHigh, PriorityHigh})
+	expected = []Priority{PriorityHigh, PriorityLow, PriorityHigh, PriorityHigh, PriorityLow, PriorityHigh, PriorityLow}
+	if !reflect.DeepEqual(order, expected) {
+		t.Fatal("weighted order not expected", order)
+	}
+}
+
+func TestPoolKeepAlive(t *testing.T) {
+	config := NewConfig()
+	config.IdleTimeout = 100 * time.Millisecond
+	config.MinIdleWorkers = 1
+	p := NewPool("test", 4, config)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		p.Go(func() {
+			time.Sleep(10 * time.Millisecond)
+			wg.Done()
+		})
+	}
+	wg.Wait()
+	time.Sleep(20 * time.Millisecond)
+	if p.WorkerCount() != 4 {
+		t.Fatal("idle workers must be kept until IdleTimeout", p.WorkerCount())
+	}
+	time.Sleep(200 * time.Millisecond)
+	if p.WorkerCount() != 1 {
+		t.Fatal("only MinIdleWorkers workers must be kept", p.WorkerCount())
+	}
+
+	done := make(chan struct{})
+	p.Go(func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("parked worker must be woken up")
+	}
+	if p.WorkerCount() != 1 {
+		t.Fatal("parked worker must be reused", p.WorkerCount())
+	}
+	if err := p.Shutdown(context.Background()); err != nil || p.WorkerCount() != 0 {
+		t.Fatal("parked workers must exit after Shutdown", err, p.WorkerCount())
+	}
+}
+
+func TestPoolShutdown(t *testing.T) {
+	p := NewPool("test", 2, NewConfig())
+	var n int32
+	for i := 0; i < 10; i++ {
+		p.Go(func() {
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&n, 1)
+		})
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Fatal("queued tasks must be done after Shutdown", n)
+	}
+	if err := p.TryGo(context.Background(), func() {}); err != ErrPoolClosed {
+		t.Fatal("closed pool must reject tasks", err)
+	}
+
+	p = NewPool("test", 1, NewConfig())
+	p.Go(func() { time.Sleep(time.Second) })
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatal("Shutdown must return when ctx is done", err)
+	}
+}
+
+func TestShutdownAll(t *testing.T) {
+	p := NewPool("test_shutdown_all", 1, NewConfig())
+	if err := RegisterPool(p); err != nil {
+		t.Fatal(err)
+	}
+	defer poolMap.Delete(p.Name())
+	var done int32
+	p.Go(func() {
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt32(&done, 1)
+	})
+	if err := ShutdownAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&done) != 1 {
+		t.Fatal("registered pool must be drained")
+	}
 }
 
 func BenchmarkPool(b *testing.B) {
@@ -74,6 +439,112 @@ func BenchmarkPool(b *testing.B) {
 	}
 }
 
+func benchmarkPriority(b *testing.B, scheduling Scheduling) {
+	config := NewConfig()
+	config.Scheduling = scheduling
+	p := NewPool("benchmark", int32(runtime.GOMAXPROCS(0)), config)
+	var wg sync.WaitGroup
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		wg.Add(benchmarkTimes)
+		for j := 0; j < benchmarkTimes; j++ {
+			p.CtxGoPriority(ctx, Priority(j%numPriorities), func() {
+				testFunc()
+				wg.Done()
+			})
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkPoolPriorityStrict(b *testing.B) {
+	benchmarkPriority(b, SchedulingStrict)
+}
+
+func BenchmarkPoolPriorityWeighted(b *testing.B) {
+	benchmarkPriority(b, SchedulingWeighted)
+}
+
+// tasks come in small bursts, workers exit between bursts without keep-alive
+func benchmarkBurst(b *testing.B, config *Config) {
+	p := NewPool("benchmark", int32(runtime.GOMAXPROCS(0)), config)
+	var wg sync.WaitGroup
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < benchmarkTimes/100; j++ {
+			wg.Add(100)
+			for k := 0; k < 100; k++ {
+				p.Go(func() {
+					testFunc()
+					wg.Done()
+				})
+			}
+			wg.Wait()
+		}
+	}
+}
+
+func BenchmarkPoolBurst(b *testing.B) {
+	benchmarkBurst(b, NewConfig())
+}
+
+func BenchmarkPoolBurstKeepAlive(b *testing.B) {
+	config := NewConfig()
+	config.IdleTimeout = time.Second
+	config.MinIdleWorkers = int32(runtime.GOMAXPROCS(0))
+	benchmarkBurst(b, config)
+}
+
+// many goroutines submit at the same time
+func benchmarkParallelSubmit(b *testing.B, config *Config) {
+	p := NewPool("benchmark", int32(runtime.GOMAXPROCS(0)), config)
+	var wg sync.WaitGroup
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			wg.Add(1)
+			p.Go(func() {
+				testFunc()
+				wg.Done()
+			})
+		}
+	})
+	wg.Wait()
+}
+
+func BenchmarkPoolParallelSubmit(b *testing.B) {
+	benchmarkParallelSubmit(b, NewConfig())
+}
+
+func BenchmarkPoolParallelSubmitSharded(b *testing.B) {
+	config := NewConfig()
+	config.QueueType = QueueSharded
+	benchmarkParallelSubmit(b, config)
+}
+
+func BenchmarkPoolSharded(b *testing.B) {
+	config := NewConfig()
+	config.QueueType = QueueSharded
+	p := NewPool("benchmark", int32(runtime.GOMAXPROCS(0)), config)
+	var wg sync.WaitGroup
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		wg.Add(benchmarkTimes)
+		for j := 0; j < benchmarkTimes; j++ {
+			p.Go(func() {
+				testFunc()
+				wg.Done()
+			})
+		}
+		wg.Wait()
+	}
+}
+
 func BenchmarkGo(b *testing.B) {
 	var wg sync.WaitGroup
 	b.ReportAllocs()
@@ -0,0 +1,156 @@
+package gopool
+
+// Priority is the lane a task is queued in, tasks in the same lane run in submission order.
+type Priority int
+
+const (
+	// PriorityHigh is for user-facing work.
+	PriorityHigh Priority = iota
+	// PriorityNormal is used by Go and CtxGo.
+	PriorityNormal
+	// PriorityLow is for batch work such as backfills.
+	PriorityLow
+
+	numPriorities = 3
+)
+
+// Scheduling decides which lane a worker takes the next task from.
+type Scheduling int
+
+const (
+	// SchedulingStrict always takes the highest priority lane, but a lower lane
+	// passed over StarvationLimit times in a row gets one task to avoid starvation.
+	SchedulingStrict Scheduling = iota
+	// SchedulingWeighted takes tasks from the lanes in proportion to LaneWeights.
+	SchedulingWeighted
+)
+
+const defaultStarvationLimit = 64
+
+var defaultLaneWeights = [numPriorities]int{8, 4, 1}
+
+type taskList struct {
+	taskHead *task
+	taskTail *task
+	// strict: times passed over while not empty; weighted: current weight of smooth round robin
+	credit int
+}
+
+func (l *taskList) push(t *task) {
+	if l.taskHead == nil {
+		l.taskHead = t
+		l.taskTail = t
+	} else {
+		l.taskTail.next = t
+		l.taskTail = t
+	}
+}
+
+func (l *taskList) pop() *task {
+	t := l.taskHead
+	if t != nil {
+		l.taskHead = t.next
+		t.next = nil
+	}
+	return t
+}
+
+func (p Priority) lane() int {
+	if p < PriorityHigh {
+		return int(PriorityHigh)
+	}
+	if p > PriorityLow {
+		return int(PriorityLow)
+	}
+	return int(p)
+}
+
+// laneSet holds the priority lanes of a queue, it's not thread-safe.
+type laneSet struct {
+	lanes           [numPriorities]taskList
+	scheduling      Scheduling
+	weights         [numPriorities]int
+	starvationLimit int
+}
+
+func newLaneSet(config *Config) laneSet {
+	s := laneSet{
+		scheduling:      config.Scheduling,
+		weights:         defaultLaneWeights,
+		starvationLimit: config.StarvationLimit,
+	}
+	for i, w := range config.LaneWeights {
+		if i < numPriorities && w > 0 {
+			s.weights[i] = w
+		}
+	}
+	if s.starvationLimit <= 0 {
+		s.starvationLimit = defaultStarvationLimit
+	}
+	return s
+}
+
+func (s *laneSet) push(t *task, priority Priority) {
+	s.lanes[priority.lane()].push(t)
+}
+
+// dropOldest removes the oldest task of the lowest priority lane.
+func (s *laneSet) dropOldest() *task {
+	for i := numPriorities - 1; i >= 0; i-- {
+		if t := s.lanes[i].pop(); t != nil {
+			return t
+		}
+	}
+	return nil
+}
+
+// next takes the next task by the scheduling, returns nil if all lanes are empty.
+func (s *laneSet) next() *task {
+	pick := -1
+	if s.scheduling == SchedulingWeighted {
+		// smooth weighted round robin among the non-empty lanes
+		total := 0
+		for i := range s.lanes {
+			l := &s.lanes[i]
+			if l.taskHead == nil {
+				l.credit = 0
+				continue
+			}
+			l.credit += s.weights[i]
+			total += s.weights[i]
+			if pick == -1 || l.credit > s.lanes[pick].credit {
+				pick = i
+			}
+		}
+		if pick == -1 {
+			return nil
+		}
+		s.lanes[pick].credit -= total
+		return s.lanes[pick].pop()
+	}
+
+	starved := -1
+	for i := range s.lanes {
+		l := &s.lanes[i]
+		if l.taskHead == nil {
+			l.credit = 0
+			continue
+		}
+		if pick == -1 {
+			pick = i
+			continue
+		}
+		l.credit++
+		if starved == -1 && l.credit > s.starvationLimit {
+			starved = i
+		}
+	}
+	if pick == -1 {
+		return nil
+	}
+	if starved != -1 {
+		pick = starved
+	}
+	s.lanes[pick].credit = 0
+	return s.lanes[pick].pop()
+}
@@ -0,0 +1,66 @@
+package gopool
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector exports the Stats of the global default pool and all the registered pools.
+//
+//	prometheus.MustRegister(gopool.NewCollector("myapp"))
+type Collector struct {
+	workers   *prometheus.Desc
+	queued    *prometheus.Desc
+	tasks     *prometheus.Desc
+	queueWait *prometheus.Desc
+	execTime  *prometheus.Desc
+}
+
+func NewCollector(namespace string) *Collector {
+	return &Collector{
+		workers: prometheus.NewDesc(prometheus.BuildFQName(namespace, "gopool", "workers"),
+			"Number of running workers.", []string{"pool"}, nil),
+		queued: prometheus.NewDesc(prometheus.BuildFQName(namespace, "gopool", "queued_tasks"),
+			"Number of tasks waiting in the queue.", []string{"pool"}, nil),
+		tasks: prometheus.NewDesc(prometheus.BuildFQName(namespace, "gopool", "tasks_total"),
+			"Number of tasks by state: submitted, completed, panicked, rejected or skipped.", []string{"pool", "state"}, nil),
+		queueWait: prometheus.NewDesc(prometheus.BuildFQName(namespace, "gopool", "queue_wait_seconds"),
+			"Time tasks waited in the queue.", []string{"pool"}, nil),
+		execTime: prometheus.NewDesc(prometheus.BuildFQName(namespace, "gopool", "exec_seconds"),
+			"Execution time of tasks.", []string{"pool"}, nil),
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.workers
+	ch <- c.queued
+	ch <- c.tasks
+	ch <- c.queueWait
+	ch <- c.execTime
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, p := range allPools() {
+		s := p.Stats()
+		ch <- prometheus.MustNewConstMetric(c.workers, prometheus.GaugeValue, float64(s.WorkerCount), s.Name)
+		ch <- prometheus.MustNewConstMetric(c.queued, prometheus.GaugeValue, float64(s.TaskCount), s.Name)
+		for state, v := range map[string]uint64{
+			"submitted": s.Submitted,
+			"completed": s.Completed,
+			"panicked":  s.Panicked,
+			"rejected":  s.Rejected,
+			"skipped":   s.Skipped,
+		} {
+			ch <- prometheus.MustNewConstMetric(c.tasks, prometheus.CounterValue, float64(v), s.Name, state)
+		}
+		ch <- constHistogram(c.queueWait, s.QueueWait, s.Name)
+		ch <- constHistogram(c.execTime, s.ExecTime, s.Name)
+	}
+}
+
+func constHistogram(desc *prometheus.Desc, h HistogramSnapshot, name string) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(h.Buckets))
+	for i, b := range h.Buckets {
+		buckets[b] = h.Counts[i]
+	}
+	return prometheus.MustNewConstHistogram(desc, h.Count, h.Sum, buckets, name)
+}
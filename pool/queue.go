@@ -0,0 +1,131 @@
+package gopool
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// QueueType selects the implementation of the task queue of a pool.
+type QueueType int
+
+const (
+	// QueueLocked keeps all the tasks in one set of lists guarded by a single mutex.
+	QueueLocked QueueType = iota
+	// QueueSharded spreads tasks over several shards with their own mutex to reduce contention.
+	// Workers take tasks from their own shard first and steal from the others when it's empty.
+	// Tasks run in submission order and by priority only within a shard.
+	QueueSharded
+)
+
+type taskQueue interface {
+	// push adds t to the lane of priority.
+	push(t *task, priority Priority)
+	// pop takes the next task for w, returns nil if the queue is empty.
+	pop(w *worker) *task
+	// dropOldest removes the oldest task of the lowest priority lane, returns nil if the queue is empty.
+	dropOldest() *task
+}
+
+func newTaskQueue(config *Config) taskQueue {
+	if config.QueueType != QueueSharded {
+		return &lockedQueue{laneSet: newLaneSet(config)}
+	}
+	shards := config.Shards
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	q := &shardedQueue{shards: make([]queueShard, shards)}
+	for i := range q.shards {
+		q.shards[i].laneSet = newLaneSet(config)
+	}
+	return q
+}
+
+type lockedQueue struct {
+	sync.Mutex
+	laneSet
+}
+
+func (q *lockedQueue) push(t *task, priority Priority) {
+	q.Lock()
+	q.laneSet.push(t, priority)
+	q.Unlock()
+}
+
+func (q *lockedQueue) pop(w *worker) *task {
+	q.Lock()
+	t := q.next()
+	q.Unlock()
+	return t
+}
+
+func (q *lockedQueue) dropOldest() *task {
+	q.Lock()
+	t := q.laneSet.dropOldest()
+	q.Unlock()
+	return t
+}
+
+type queueShard struct {
+	sync.Mutex
+	laneSet
+	// number of tasks, checked without lock when stealing
+	size int32
+	// keep shards in different cache lines
+	_ [64]byte
+}
+
+type shardedQueue struct {
+	shards []queueShard
+	next   uint32
+}
+
+func (q *shardedQueue) push(t *task, priority Priority) {
+	s := &q.shards[atomic.AddUint32(&q.next, 1)%uint32(len(q.shards))]
+	s.Lock()
+	s.laneSet.push(t, priority)
+	atomic.AddInt32(&s.size, 1)
+	s.Unlock()
+}
+
+func (q *shardedQueue) pop(w *worker) *task {
+	n := len(q.shards)
+	for i := 0; i < n; i++ {
+		s := &q.shards[(w.shard+i)%n]
+		if atomic.LoadInt32(&s.size) == 0 {
+			continue
+		}
+		s.Lock()
+		t := s.next()
+		if t != nil {
+			atomic.AddInt32(&s.size, -1)
+		}
+		s.Unlock()
+		if t != nil {
+			return t
+		}
+	}
+	return nil
+}
+
+func (q *shardedQueue) dropOldest() *task {
+	n := len(q.shards)
+	start := int(atomic.LoadUint32(&q.next))
+	for i := 0; i < n; i++ {
+		s := &q.shards[(start+i)%n]
+		if atomic.LoadInt32(&s.size) == 0 {
+			continue
+		}
+		s.Lock()
+		t := s.laneSet.dropOldest()
+		if t != nil {
+			atomic.AddInt32(&s.size, -1)
+		}
+		s.Unlock()
+		if t != nil {
+			return t
+		}
+	}
+	return nil
+}
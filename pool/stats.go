@@ -0,0 +1,140 @@
+package gopool
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// upper bounds of the histogram buckets, from 50us to about 13s
+var histogramBuckets = func() []time.Duration {
+	buckets := make([]time.Duration, 19)
+	d := 50 * time.Microsecond
+	for i := range buckets {
+		buckets[i] = d
+		d *= 2
+	}
+	return buckets
+}()
+
+// histogram is a lock-free histogram with fixed buckets.
+type histogram struct {
+	// the last one is for values greater than all the buckets
+	counts [20]uint64
+	count  uint64
+	sum    int64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := sort.Search(len(histogramBuckets), func(i int) bool {
+		return d <= histogramBuckets[i]
+	})
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+func (h *histogram) snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{
+		Buckets: make([]float64, len(histogramBuckets)),
+		Counts:  make([]uint64, len(histogramBuckets)),
+	}
+	var cumulative uint64
+	for i, b := range histogramBuckets {
+		cumulative += atomic.LoadUint64(&h.counts[i])
+		s.Buckets[i] = b.Seconds()
+		s.Counts[i] = cumulative
+	}
+	s.Count = atomic.LoadUint64(&h.count)
+	s.Sum = time.Duration(atomic.LoadInt64(&h.sum)).Seconds()
+	return s
+}
+
+// HistogramSnapshot is a point-in-time copy of a histogram, in seconds.
+type HistogramSnapshot struct {
+	// upper bounds of the buckets
+	Buckets []float64 `json:"buckets"`
+	// cumulative counts of the buckets, values greater than the last bucket are only in Count
+	Counts []uint64 `json:"counts"`
+	Count  uint64   `json:"count"`
+	Sum    float64  `json:"sum"`
+}
+
+type poolStats struct {
+	submitted uint64
+	completed uint64
+	panicked  uint64
+	rejected  uint64
+	skipped   uint64
+
+	queueWait histogram
+	execTime  histogram
+}
+
+// Stats is a snapshot of the counters of a pool.
+type Stats struct {
+	Name        string `json:"name"`
+	Cap         int32  `json:"cap"`
+	WorkerCount int32  `json:"worker_count"`
+	TaskCount   int32  `json:"task_count"`
+
+	// tasks accepted by the pool
+	Submitted uint64 `json:"submitted"`
+	// tasks returned normally
+	Completed uint64 `json:"completed"`
+	Panicked  uint64 `json:"panicked"`
+	// tasks not accepted because the pool is full or closed, or dropped by PolicyDropOldest
+	Rejected uint64 `json:"rejected"`
+	// tasks not executed because ctx is done or TaskTimeout is exceeded
+	Skipped uint64 `json:"skipped"`
+
+	QueueWait HistogramSnapshot `json:"queue_wait"`
+	ExecTime  HistogramSnapshot `json:"exec_time"`
+}
+
+func (p *pool) Stats() Stats {
+	return Stats{
+		Name:        p.name,
+		Cap:         atomic.LoadInt32(&p.cap),
+		WorkerCount: p.WorkerCount(),
+		TaskCount:   p.TaskCount(),
+		Submitted:   atomic.LoadUint64(&p.stats.submitted),
+		Completed:   atomic.LoadUint64(&p.stats.completed),
+		Panicked:    atomic.LoadUint64(&p.stats.panicked),
+		Rejected:    atomic.LoadUint64(&p.stats.rejected),
+		Skipped:     atomic.LoadUint64(&p.stats.skipped),
+		QueueWait:   p.stats.queueWait.snapshot(),
+		ExecTime:    p.stats.execTime.snapshot(),
+	}
+}
+
+// allPools returns the global default pool and the registered pools sorted by name.
+func allPools() []Pool {
+	var pools []Pool
+	poolMap.Range(func(key, value interface{}) bool {
+		pools = append(pools, value.(Pool))
+		return true
+	})
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].Name() < pools[j].Name()
+	})
+	return append([]Pool{defaultPool}, pools...)
+}
+
+// Handler returns an http.Handler which writes the Stats of the global default pool
+// and all the registered pools as a JSON array.
+//
+//	http.Handle("/debug/gopool", gopool.Handler())
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pools := allPools()
+		stats := make([]Stats, 0, len(pools))
+		for _, p := range pools {
+			stats = append(stats, p.Stats())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	})
+}
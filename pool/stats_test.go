@@ -0,0 +1,75 @@
+package gopool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPoolStats(t *testing.T) {
+	config := NewConfig()
+	config.MaxQueueLength = 1
+	config.RejectPolicy = PolicyReject
+	p := NewPool("test_stats", 1, config)
+	p.SetPanicHandler(func(ctx context.Context, r interface{}) {})
+	if err := RegisterPool(p); err != nil {
+		t.Fatal(err)
+	}
+	defer poolMap.Delete(p.Name())
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	p.Go(func() {
+		close(started)
+		<-block
+		time.Sleep(time.Millisecond)
+	})
+	<-started
+	ctx, cancel := context.WithCancel(context.Background())
+	p.CtxGo(ctx, func() {})
+	cancel()
+	if err := p.TryGo(context.Background(), func() {}); err != ErrPoolFull {
+		t.Fatal(err)
+	}
+	close(block)
+	for p.TaskCount() > 0 || p.WorkerCount() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	p.Go(testPanicFunc)
+	for p.Stats().Panicked == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	s := p.Stats()
+	if s.Submitted != 3 || s.Completed != 1 || s.Panicked != 1 || s.Rejected != 1 || s.Skipped != 1 {
+		t.Fatalf("stats not expected: %+v", s)
+	}
+	if s.QueueWait.Count != 2 || s.ExecTime.Count != 2 || s.ExecTime.Sum < time.Millisecond.Seconds() {
+		t.Fatalf("histograms not expected: %+v %+v", s.QueueWait, s.ExecTime)
+	}
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/gopool", nil))
+	var stats []Stats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, s := range stats {
+		if s.Name == p.Name() && s.Submitted == 3 {
+			found = true
+		}
+	}
+	if !found || stats[0].Name != "gopool.DefaultPool" {
+		t.Fatal("handler must list the default and registered pools", rec.Body.String())
+	}
+
+	// 每个pool 2个gauge，5个counter，2个histogram
+	if n := testutil.CollectAndCount(NewCollector("test")); n != 9*len(stats) {
+		t.Fatal("collector metrics not expected", n)
+	}
+}
@@ -0,0 +1,231 @@
+package gopool
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TimerHandle is returned by GoAfter, GoAt and GoEvery to cancel the scheduled task.
+type TimerHandle struct {
+	s *scheduler
+	e *timerEntry
+}
+
+// Cancel stops the task, returns false if it's already due (GoAfter and GoAt), canceled, or the pool is closed.
+// After Cancel returns true, no new run starts, a run already started is not affected.
+func (h *TimerHandle) Cancel() bool {
+	h.s.lock.Lock()
+	defer h.s.lock.Unlock()
+	if h.e.canceled || h.e.index < 0 {
+		return false
+	}
+	h.e.canceled = true
+	heap.Remove(&h.s.entries, h.e.index)
+	return true
+}
+
+// Dropped returns the number of runs rejected by the pool, for example because the queue is full.
+func (h *TimerHandle) Dropped() int {
+	h.s.lock.Lock()
+	defer h.s.lock.Unlock()
+	return h.e.dropped
+}
+
+type timerEntry struct {
+	at       time.Time
+	interval time.Duration
+	ctx      context.Context
+	f        func()
+	// position in the heap, -1 if not in it
+	index    int
+	canceled bool
+	dropped  int
+}
+
+type timerHeap []*timerEntry
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	e := x.(*timerEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*h = old[:len(old)-1]
+	return e
+}
+
+// scheduler keeps the delayed tasks of a pool in a heap, one goroutine with one runtime timer
+// submits them to the pool when they are due.
+type scheduler struct {
+	pool *pool
+
+	lock    sync.Mutex
+	entries timerHeap
+	// wakes up the loop when an earlier entry is added
+	wakeup    chan struct{}
+	startOnce sync.Once
+	// set when the pool is closed, no more entries are accepted
+	stopped bool
+}
+
+func newScheduler(p *pool) *scheduler {
+	return &scheduler{pool: p, wakeup: make(chan struct{}, 1)}
+}
+
+func (s *scheduler) add(ctx context.Context, at time.Time, interval time.Duration, f func()) *TimerHandle {
+	e := &timerEntry{at: at, interval: interval, ctx: ctx, f: f, index: -1}
+	h := &TimerHandle{s: s, e: e}
+	s.startOnce.Do(func() {
+		go s.loop()
+	})
+	s.lock.Lock()
+	if s.stopped || atomic.LoadInt32(&s.pool.closed) == 1 {
+		s.lock.Unlock()
+		e.canceled = true
+		return h
+	}
+	heap.Push(&s.entries, e)
+	first := e.index == 0
+	s.lock.Unlock()
+	if first {
+		select {
+		case s.wakeup <- struct{}{}:
+		default:
+		}
+	}
+	return h
+}
+
+func (s *scheduler) loop() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	var due []*timerEntry
+	for {
+		now := time.Now()
+		next := time.Hour
+		s.lock.Lock()
+		for len(s.entries) > 0 {
+			e := s.entries[0]
+			if e.at.After(now) {
+				next = e.at.Sub(now)
+				break
+			}
+			due = append(due, e)
+			if e.interval > 0 {
+				// skip the missed runs instead of firing them in a burst
+				e.at = e.at.Add(e.interval)
+				if !e.at.After(now) {
+					e.at = now.Add(e.interval)
+				}
+				heap.Fix(&s.entries, 0)
+			} else {
+				heap.Pop(&s.entries)
+			}
+		}
+		s.lock.Unlock()
+
+		for i, e := range due {
+			s.fire(e)
+			due[i] = nil
+		}
+		due = due[:0]
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(next)
+		select {
+		case <-timer.C:
+		case <-s.wakeup:
+		case <-s.pool.closeCh:
+			s.cancelAll()
+			return
+		}
+	}
+}
+
+// fire submits a due entry to the pool with TryGo, so it never blocks the loop or runs f on it,
+// even under PolicyBlock or PolicyCallerRuns.
+func (s *scheduler) fire(e *timerEntry) {
+	if e.ctx.Err() != nil {
+		// the ctx also stops a periodic task
+		(&TimerHandle{s: s, e: e}).Cancel()
+		return
+	}
+	if s.isCanceled(e) {
+		return
+	}
+	err := s.pool.TryGo(e.ctx, func() {
+		// Cancel may be called after the run is submitted
+		if !s.isCanceled(e) {
+			e.f()
+		}
+	})
+	if err == nil {
+		return
+	}
+	s.lock.Lock()
+	e.dropped++
+	s.lock.Unlock()
+	if s.pool.config.SkipHandler != nil {
+		s.pool.config.SkipHandler(e.ctx, err)
+	}
+}
+
+func (s *scheduler) isCanceled(e *timerEntry) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return e.canceled
+}
+
+func (s *scheduler) pending() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.entries)
+}
+
+// cancelAll cancels all the pending tasks when the pool is closed.
+func (s *scheduler) cancelAll() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, e := range s.entries {
+		e.canceled = true
+		e.index = -1
+	}
+	s.entries = nil
+	s.stopped = true
+}
+
+func (p *pool) GoAfter(ctx context.Context, d time.Duration, f func()) *TimerHandle {
+	return p.timers.add(ctx, time.Now().Add(d), 0, f)
+}
+
+func (p *pool) GoAt(ctx context.Context, t time.Time, f func()) *TimerHandle {
+	return p.timers.add(ctx, t, 0, f)
+}
+
+func (p *pool) GoEvery(ctx context.Context, interval time.Duration, f func()) *TimerHandle {
+	if interval <= 0 {
+		panic("gopool: non-positive interval for GoEvery")
+	}
+	return p.timers.add(ctx, time.Now().Add(interval), interval, f)
+}
@@ -0,0 +1,218 @@
+package gopool
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGoAfter(t *testing.T) {
+	p := NewPool("test", 8, NewConfig())
+	done := make(chan time.Duration, 2)
+	start := time.Now()
+	p.GoAfter(context.Background(), 50*time.Millisecond, func() {
+		done <- time.Since(start)
+	})
+	// an earlier timer added later must not wait for the first one
+	p.GoAt(context.Background(), start.Add(10*time.Millisecond), func() {
+		done <- time.Since(start)
+	})
+	first, second := <-done, <-done
+	if first < 10*time.Millisecond || first > 40*time.Millisecond {
+		t.Fatal("GoAt fired at", first)
+	}
+	if second < 50*time.Millisecond {
+		t.Fatal("GoAfter fired too early", second)
+	}
+
+	var n int32
+	h := p.GoAfter(context.Background(), 20*time.Millisecond, func() {
+		atomic.AddInt32(&n, 1)
+	})
+	if !h.Cancel() {
+		t.Fatal("cancel a pending timer should return true")
+	}
+	if h.Cancel() {
+		t.Fatal("cancel twice should return false")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&n) != 0 {
+		t.Fatal("canceled task is executed")
+	}
+}
+
+func TestGoEvery(t *testing.T) {
+	p := NewPool("test", 8, NewConfig())
+	var n int32
+	h := p.GoEvery(context.Background(), 10*time.Millisecond, func() {
+		atomic.AddInt32(&n, 1)
+	})
+	for i := 0; i < 100 && atomic.LoadInt32(&n) < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !h.Cancel() {
+		t.Fatal("cancel a periodic timer should return true")
+	}
+	time.Sleep(20 * time.Millisecond)
+	stopped := atomic.LoadInt32(&n)
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&n) != stopped {
+		t.Fatal("periodic task is executed after cancel")
+	}
+
+	// ctx also stops the periodic task
+	ctx, cancel := context.WithCancel(context.Background())
+	var m int32
+	p.GoEvery(ctx, 10*time.Millisecond, func() {
+		atomic.AddInt32(&m, 1)
+	})
+	for i := 0; i < 100 && atomic.LoadInt32(&m) < 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	time.Sleep(30 * time.Millisecond)
+	stopped = atomic.LoadInt32(&m)
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&m) != stopped {
+		t.Fatal("periodic task is executed after ctx is done")
+	}
+}
+
+func TestTimerPoolClose(t *testing.T) {
+	p := NewPool("test", 8, NewConfig())
+	var n int32
+	handles := []*TimerHandle{
+		p.GoAfter(context.Background(), time.Hour, func() { atomic.AddInt32(&n, 1) }),
+		p.GoEvery(context.Background(), time.Hour, func() { atomic.AddInt32(&n, 1) }),
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// the scheduler cancels the pending timers when it sees the pool closed
+	s := p.(*pool).timers
+	for i := 0; i < 100 && s.pending() > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	for _, h := range handles {
+		if h.Cancel() {
+			t.Fatal("timers should be canceled on close")
+		}
+	}
+	after := p.GoAfter(context.Background(), 0, func() { atomic.AddInt32(&n, 1) })
+	if after.Cancel() {
+		t.Fatal("timer added after close should be canceled")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if atomic.LoadInt32(&n) != 0 {
+		t.Fatal("timer added after close is executed")
+	}
+}
+
+func BenchmarkGoAfter(b *testing.B) {
+	p := NewPool("benchmark", 8, NewConfig())
+	defer p.Close()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.GoAfter(context.Background(), time.Hour, func() {}).Cancel()
+		}
+	})
+}
+
+func TestTimerDropped(t *testing.T) {
+	config := NewConfig()
+	config.MaxQueueLength = 1
+	config.RejectPolicy = PolicyReject
+	var skipped int32
+	config.SkipHandler = func(ctx context.Context, err error) {
+		if err == ErrPoolFull {
+			atomic.AddInt32(&skipped, 1)
+		}
+	}
+	p := NewPool("test", 1, config)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	p.Go(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	p.Go(func() {})
+
+	ran := int32(0)
+	h := p.GoAfter(context.Background(), time.Millisecond, func() { atomic.AddInt32(&ran, 1) })
+	for i := 0; i < 100 && atomic.LoadInt32(&skipped) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	close(block)
+	if h.Dropped() != 1 || atomic.LoadInt32(&skipped) != 1 {
+		t.Fatal("rejected run must be reported", h.Dropped(), skipped)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if atomic.LoadInt32(&ran) != 0 {
+		t.Fatal("rejected run is executed")
+	}
+}
+
+func TestTimerCancelQueued(t *testing.T) {
+	// a run submitted but not started yet must not start after Cancel returns true
+	p := NewPool("test", 1, NewConfig())
+	block := make(chan struct{})
+	started := make(chan struct{})
+	p.Go(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	var n int32
+	h := p.GoEvery(context.Background(), 5*time.Millisecond, func() { atomic.AddInt32(&n, 1) })
+	for i := 0; i < 100 && p.TaskCount() == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if !h.Cancel() {
+		t.Fatal("cancel a periodic timer should return true")
+	}
+	close(block)
+	time.Sleep(20 * time.Millisecond)
+	if atomic.LoadInt32(&n) != 0 {
+		t.Fatal("periodic task is executed after cancel", n)
+	}
+}
+
+func TestTimerCallerRuns(t *testing.T) {
+	// a due run must not be executed by the scheduler when the queue is full
+	config := NewConfig()
+	config.MaxQueueLength = 1
+	config.RejectPolicy = PolicyCallerRuns
+	p := NewPool("test", 1, config)
+	block := make(chan struct{})
+	defer close(block)
+	started := make(chan struct{})
+	p.Go(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	p.Go(func() {})
+
+	var ran int32
+	h := p.GoAfter(context.Background(), time.Millisecond, func() {
+		atomic.AddInt32(&ran, 1)
+		<-block
+	})
+	for i := 0; i < 100 && h.Dropped() == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if h.Dropped() != 1 || atomic.LoadInt32(&ran) != 0 {
+		t.Fatal("run must be dropped instead of running on the scheduler", h.Dropped(), ran)
+	}
+	// the scheduler is not blocked and goes on with the next timer
+	h = p.GoAfter(context.Background(), time.Millisecond, func() {})
+	for i := 0; i < 100 && h.Dropped() == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if h.Dropped() != 1 {
+		t.Fatal("scheduler is blocked")
+	}
+}
@@ -15,11 +15,9 @@
 package gopool
 
 import (
-	"fmt"
-	"log"
-	"runtime/debug"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 var workerPool sync.Pool
@@ -30,6 +28,8 @@ func init() {
 
 type worker struct {
 	pool *pool
+	// the shard tried first by QueueSharded
+	shard int
 }
 
 func newWorker() interface{} {
@@ -39,38 +39,82 @@ func newWorker() interface{} {
 func (w *worker) run() {
 	go func() {
 		//这里起了一个for循环 新建的所有worker会一直从task链表里取任务 直到取完
+		p := w.pool
+		idleExpired := false
 		for {
-			var t *task
-			w.pool.taskLock.Lock() //worker取任务执行要加锁
-			if w.pool.taskHead != nil {
-				t = w.pool.taskHead
-				w.pool.taskHead = w.pool.taskHead.next
-				atomic.AddInt32(&w.pool.taskCount, -1)
+			t := p.queue.pop(w)
+			if t != nil {
+				atomic.AddInt32(&p.taskCount, -1)
+				if p.slots != nil {
+					<-p.slots
+				}
+				idleExpired = false
+				p.execute(t)
+				t.Recycle()
+				continue
 			}
-			if t == nil {
-				// if there's no task to do, exit
-				w.close()
-				w.pool.taskLock.Unlock()
-				w.Recycle() // worker回收利用 实际执行：1.worker置零值，放回workerPool
-				return
+			if !idleExpired && p.keepAlive() {
+				// park instead of exiting. Count as idle before checking taskCount,
+				// so that a submitter either sees this worker idle or this worker sees its task.
+				atomic.AddInt32(&p.idleCount, 1)
+				if p.TaskCount() == 0 {
+					idleExpired = !p.park()
+				}
+				atomic.AddInt32(&p.idleCount, -1)
+				continue
 			}
-			w.pool.taskLock.Unlock()
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						if w.pool.panicHandler != nil {
-							w.pool.panicHandler(t.ctx, r)
-						} else {
-							msg := fmt.Sprintf("GOPOOL: panic in pool: %s: %v: %s", w.pool.name, r, debug.Stack())
-							log.Fatal(msg)
-						}
-					}
-				}()
-				t.f()
-			}()
-			t.Recycle()
+			// if there's no task to do, exit
+			w.close()
+			// a submitter may have seen this worker still running and not started a new one
+			if p.TaskCount() > 0 {
+				p.incWorkerCount()
+				continue
+			}
+			w.Recycle() // worker回收利用 实际执行：1.worker置零值，放回workerPool
+			return
+		}
+	}()
+}
+
+func (p *pool) keepAlive() bool {
+	return (p.config.IdleTimeout > 0 || p.config.MinIdleWorkers > 0) && atomic.LoadInt32(&p.closed) == 0
+}
+
+// park waits for a wakeup from submitters, returns false if IdleTimeout is exceeded or the pool is closed.
+// The first MinIdleWorkers parked workers wait without timeout.
+func (p *pool) park() bool {
+	var timeout <-chan time.Time
+	if atomic.AddInt32(&p.warmCount, 1) <= p.config.MinIdleWorkers {
+		defer atomic.AddInt32(&p.warmCount, -1)
+	} else {
+		atomic.AddInt32(&p.warmCount, -1)
+		timer := time.NewTimer(p.config.IdleTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-p.wakeup:
+		return true
+	case <-timeout:
+		return false
+	case <-p.closeCh:
+		return false
+	}
+}
+
+// runTask runs f and recovers the panic, it's also used by PolicyCallerRuns.
+func (p *pool) runTask(t *task) {
+	start := time.Now()
+	defer func() {
+		p.stats.execTime.observe(time.Since(start))
+		if r := recover(); r != nil {
+			atomic.AddUint64(&p.stats.panicked, 1)
+			p.handlePanic(t, r)
+		} else {
+			atomic.AddUint64(&p.stats.completed, 1)
 		}
 	}()
+	t.f()
 }
 
 func (w *worker) close() {
@@ -79,6 +123,7 @@ func (w *worker) close() {
 
 func (w *worker) zero() {
 	w.pool = nil
+	w.shard = 0
 }
 
 func (w *worker) Recycle() {